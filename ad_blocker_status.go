@@ -2,10 +2,10 @@ package pihole
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 )
 
 type AdBlocker interface {
@@ -14,6 +14,9 @@ type AdBlocker interface {
 
 	// Update updates the ad blocker status (enabled, disabled)
 	Update(ctx context.Context, opts AdBlockerStatusOptions) (*AdBlockerStatus, error)
+
+	// WaitUntilEnabled polls the status every poll interval until blocking is enabled
+	WaitUntilEnabled(ctx context.Context, poll time.Duration) error
 }
 
 type AdBlockerStatusOptions struct {
@@ -29,14 +32,23 @@ type adBlockerStatusResponse struct {
 	Status string `json:"status"`
 }
 
+// NoTimer is the TimerSeconds of a status without a pending re-enable
+const NoTimer = -1
+
 // AdBlockerStatus is an object representing the ad blocker status
 type AdBlockerStatus struct {
 	Enabled bool
+
+	// TimerSeconds is the time left until blocking is enabled again, or NoTimer when blocking
+	// is enabled, disabled indefinitely, or the remaining time is unknown. Pi-hole v5 does not
+	// report the timer, so only Update knows it, right after disabling for DisabledSeconds.
+	TimerSeconds int
 }
 
 func (res adBlockerStatusResponse) toAdBlockerStatus() *AdBlockerStatus {
 	return &AdBlockerStatus{
-		Enabled: strings.EqualFold(res.Status, "enabled"),
+		Enabled:      strings.EqualFold(res.Status, "enabled"),
+		TimerSeconds: NoTimer,
 	}
 }
 
@@ -54,18 +66,22 @@ func (ab adBlocker) Get(ctx context.Context) (*AdBlockerStatus, error) {
 		return nil, err
 	}
 
-	defer res.Body.Close()
+	defer drainAndClose(res.Body)
 
 	var status *adBlockerStatusResponse
-	if err := json.NewDecoder(res.Body).Decode(&status); err != nil {
-		return nil, fmt.Errorf("failed to parse ad blocker status body: %w", err)
+	if err := ab.client.decode(res.Body, &status); err != nil {
+		return nil, decodeError("ad blocker status", err)
 	}
 
 	return status.toAdBlockerStatus(), nil
 }
 
 // Update changes the ad blocker status state
-func (ab adBlocker) Update(ctx context.Context, opts AdBlockerStatusOptions) (*AdBlockerStatus, error) {
+func (ab adBlocker) Update(ctx context.Context, opts AdBlockerStatusOptions) (status *AdBlockerStatus, err error) {
+	defer func() {
+		ab.client.audit(ctx, AuditEvent{Action: AuditUpdateAdBlocker, Err: err})
+	}()
+
 	action := "enable"
 	val := fmt.Sprint(true)
 
@@ -87,12 +103,42 @@ func (ab adBlocker) Update(ctx context.Context, opts AdBlockerStatusOptions) (*A
 		return nil, err
 	}
 
-	defer res.Body.Close()
+	defer drainAndClose(res.Body)
 
-	var status *adBlockerStatusResponse
-	if err := json.NewDecoder(res.Body).Decode(&status); err != nil {
-		return nil, fmt.Errorf("failed to parse ad blocker status body: %w", err)
+	var statusRes *adBlockerStatusResponse
+	if err := ab.client.decode(res.Body, &statusRes); err != nil {
+		return nil, decodeError("ad blocker status", err)
 	}
 
-	return status.toAdBlockerStatus(), nil
+	status = statusRes.toAdBlockerStatus()
+	if !status.Enabled && opts.DisabledSeconds > 0 {
+		status.TimerSeconds = opts.DisabledSeconds
+	}
+
+	return status, nil
+}
+
+// WaitUntilEnabled blocks until the ad blocker reports enabled, e.g. once a disable timer
+// elapsed, polling every poll interval. It returns the context error if ctx ends first, and
+// an error without polling when poll is not positive.
+func (ab adBlocker) WaitUntilEnabled(ctx context.Context, poll time.Duration) error {
+	if poll <= 0 {
+		return fmt.Errorf("poll interval must be positive, got %s", poll)
+	}
+
+	for {
+		status, err := ab.Get(ctx)
+		if err != nil {
+			return err
+		}
+		if status.Enabled {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ab.client.after(poll):
+		}
+	}
 }
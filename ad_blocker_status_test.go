@@ -2,7 +2,11 @@ package pihole
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -47,3 +51,99 @@ func TestAdBlocker(t *testing.T) {
 		assert.Equal(t, status.Enabled, false)
 	})
 }
+
+func TestAdBlockerTimer(t *testing.T) {
+	t.Run("report the timer after disabling for a duration", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "300", r.URL.Query().Get("disable"))
+			fmt.Fprint(w, `{"status":"disabled"}`)
+		})
+
+		status, err := c.AdBlocker.Update(context.Background(), AdBlockerStatusOptions{DisabledSeconds: 300})
+		require.NoError(t, err)
+
+		assert.Equal(t, AdBlockerStatus{Enabled: false, TimerSeconds: 300}, *status)
+	})
+
+	t.Run("report no timer when disabled indefinitely", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"status":"disabled"}`)
+		})
+
+		status, err := c.AdBlocker.Update(context.Background(), AdBlockerStatusOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, AdBlockerStatus{Enabled: false, TimerSeconds: NoTimer}, *status)
+	})
+
+	t.Run("report no timer from get", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"status":"enabled"}`)
+		})
+
+		status, err := c.AdBlocker.Get(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, AdBlockerStatus{Enabled: true, TimerSeconds: NoTimer}, *status)
+	})
+}
+
+func TestAdBlockerWaitUntilEnabled(t *testing.T) {
+	t.Run("return once blocking is enabled again", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		var polls int32
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&polls, 1) < 3 {
+				fmt.Fprint(w, `{"status":"disabled"}`)
+				return
+			}
+			fmt.Fprint(w, `{"status":"enabled"}`)
+		})
+
+		err := c.AdBlocker.WaitUntilEnabled(context.Background(), time.Millisecond)
+		require.NoError(t, err)
+
+		assert.Equal(t, int32(3), atomic.LoadInt32(&polls))
+	})
+
+	t.Run("error when the context ends first", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"status":"disabled"}`)
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := c.AdBlocker.WaitUntilEnabled(ctx, 10*time.Millisecond)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("error on a poll interval that is not positive", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			t.Errorf("unexpected request: %s", r.URL)
+		})
+
+		for _, poll := range []time.Duration{0, -time.Second} {
+			err := c.AdBlocker.WaitUntilEnabled(context.Background(), poll)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "poll interval")
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package pihole
+
+import (
+	"context"
+	"time"
+)
+
+// AuditAction names a state change made through the client
+type AuditAction string
+
+const (
+	AuditCreateDNS        AuditAction = "dns.create"
+	AuditDeleteDNS        AuditAction = "dns.delete"
+	AuditCreateCNAME      AuditAction = "cname.create"
+	AuditDeleteCNAME      AuditAction = "cname.delete"
+	AuditUpdateAdBlocker  AuditAction = "adblocker.update"
+	AuditAddListDomain    AuditAction = "list.add"
+	AuditDeleteListDomain AuditAction = "list.delete"
+)
+
+// AuditEvent describes a single mutation sent to Pi-hole and its result
+type AuditEvent struct {
+	Action AuditAction
+	Domain string
+	IP     string
+	Target string
+	Time   time.Time
+
+	// List is the domain list changed by a list action
+	List ListKind
+
+	// RequestID is the correlation ID carried by the request context, if any
+	RequestID string
+
+	// Err is nil when the mutation succeeded
+	Err error
+}
+
+// audit reports a mutation to the configured audit function, if any
+func (c Client) audit(ctx context.Context, event AuditEvent) {
+	if c.auditFunc == nil {
+		return
+	}
+
+	event.Time = c.now()
+	event.RequestID, _ = RequestIDFromContext(ctx)
+	c.auditFunc(event)
+}
@@ -0,0 +1,94 @@
+package pihole
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAudit(t *testing.T) {
+	t.Run("report DNS mutations", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		var mu sync.Mutex
+		var events []AuditEvent
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{
+			AuditFunc: func(event AuditEvent) {
+				mu.Lock()
+				defer mu.Unlock()
+				events = append(events, event)
+			},
+		}, fake.ServeHTTP)
+
+		ctx := context.Background()
+
+		_, err := c.LocalDNS.Create(ctx, "test.lan", "127.0.0.1")
+		require.NoError(t, err)
+
+		err = c.LocalDNS.Delete(ctx, "test.lan")
+		require.NoError(t, err)
+
+		err = c.LocalDNS.(*localDNS).deleteRecord(ctx, DNSRecord{Domain: "test.lan", IP: "127.0.0.1"})
+		require.Error(t, err)
+
+		require.Len(t, events, 3)
+
+		assert.Equal(t, AuditCreateDNS, events[0].Action)
+		assert.Equal(t, "test.lan", events[0].Domain)
+		assert.Equal(t, "127.0.0.1", events[0].IP)
+		assert.NoError(t, events[0].Err)
+		assert.False(t, events[0].Time.IsZero())
+
+		assert.Equal(t, AuditDeleteDNS, events[1].Action)
+		assert.NoError(t, events[1].Err)
+
+		assert.Equal(t, AuditDeleteDNS, events[2].Action)
+		assert.Error(t, events[2].Err)
+	})
+}
+
+func TestAuditDomainList(t *testing.T) {
+	t.Run("report one event per list domain", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		var mu sync.Mutex
+		var events []AuditEvent
+
+		c := newUnitTestClient(t, Config{
+			AuditFunc: func(event AuditEvent) {
+				mu.Lock()
+				defer mu.Unlock()
+				events = append(events, event)
+			},
+		}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"success":true,"message":"Added 2 domains"}`)
+		})
+
+		ctx := context.Background()
+
+		_, err := c.DomainList.AddMany(ctx, []string{"a.example.com", "b.example.com"}, Blacklist)
+		require.NoError(t, err)
+
+		require.NoError(t, c.DomainList.Delete(ctx, "a.example.com", Blacklist))
+
+		require.Len(t, events, 3)
+
+		assert.Equal(t, AuditAddListDomain, events[0].Action)
+		assert.Equal(t, "a.example.com", events[0].Domain)
+		assert.Equal(t, Blacklist, events[0].List)
+		assert.Equal(t, "b.example.com", events[1].Domain)
+
+		assert.Equal(t, AuditDeleteListDomain, events[2].Action)
+		assert.Equal(t, "a.example.com", events[2].Domain)
+		assert.NoError(t, events[2].Err)
+	})
+}
@@ -0,0 +1,103 @@
+package pihole
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrBackupFailed       = errors.New("backup failed")
+	ErrIncompatibleBackup = errors.New("incompatible backup format")
+)
+
+// BackupVersion is the schema version of the JSON written by ExportJSON and the only one
+// RestoreJSON accepts
+const BackupVersion = 1
+
+// backupFile is the JSON document written by ExportJSON
+type backupFile struct {
+	Version int           `json:"version"`
+	Records DNSRecordList `json:"records"`
+}
+
+// ExportJSON writes every custom DNS record to w as a versioned JSON document, in server
+// order
+func (dns localDNS) ExportJSON(ctx context.Context, w io.Writer) error {
+	list, err := dns.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch custom DNS records: %w", err)
+	}
+
+	return writeBackup(w, list)
+}
+
+func writeBackup(w io.Writer, list DNSRecordList) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(backupFile{Version: BackupVersion, Records: append(DNSRecordList{}, list...)})
+}
+
+// backupBeforeDelete writes list to the writer Config.BackupBeforeDelete provides, if set.
+// Any failure wraps ErrBackupFailed so the caller can abort before deleting anything.
+func (dns localDNS) backupBeforeDelete(list DNSRecordList) error {
+	if dns.client.backupFunc == nil {
+		return nil
+	}
+
+	w, err := dns.client.backupFunc()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrBackupFailed, err)
+	}
+
+	if err := writeBackup(w, list); err != nil {
+		w.Close()
+		return fmt.Errorf("%w: %w", ErrBackupFailed, err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("%w: %w", ErrBackupFailed, err)
+	}
+
+	return nil
+}
+
+// RestoreMode selects how RestoreJSON applies a backup
+type RestoreMode int
+
+const (
+	// RestoreMerge adds the backed up records that are missing and keeps all others
+	RestoreMerge RestoreMode = iota
+
+	// RestoreReplace also deletes the records that are not in the backup, so the server
+	// matches it exactly
+	RestoreReplace
+)
+
+// RestoreJSON reads a backup written by ExportJSON and applies it with Reconcile, deleting
+// records only in RestoreReplace mode. A document of another schema version fails with
+// ErrIncompatibleBackup, and invalid records fail validation, both before any change.
+func (dns localDNS) RestoreJSON(ctx context.Context, r io.Reader, mode RestoreMode) error {
+	if mode != RestoreMerge && mode != RestoreReplace {
+		return fmt.Errorf("unknown restore mode %d", mode)
+	}
+
+	var backup backupFile
+	if err := json.NewDecoder(r).Decode(&backup); err != nil {
+		return fmt.Errorf("failed to parse backup: %w", err)
+	}
+
+	if backup.Version != BackupVersion {
+		return fmt.Errorf("%w: version %d, expected %d", ErrIncompatibleBackup, backup.Version, BackupVersion)
+	}
+
+	_, err := dns.Reconcile(ctx, backup.Records, ReconcileOptions{AllowDelete: mode == RestoreReplace})
+	if err != nil {
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package pihole
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// backupBuffer collects a backup in memory and records whether it was closed
+type backupBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *backupBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestLocalDNSExportJSON(t *testing.T) {
+	t.Run("write a versioned document", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, newFakeLocalDNS(DNSRecord{Domain: "a.lan", IP: "10.0.0.1"}).ServeHTTP)
+
+		var buf bytes.Buffer
+		require.NoError(t, c.LocalDNS.ExportJSON(context.Background(), &buf))
+
+		assert.JSONEq(t, `{"version":1,"records":[{"domain":"a.lan","ip":"10.0.0.1"}]}`, buf.String())
+	})
+}
+
+func TestBackupBeforeDelete(t *testing.T) {
+	records := []DNSRecord{
+		{Domain: "a.lan", IP: "10.0.0.1"},
+		{Domain: "b.lan", IP: "10.0.0.2"},
+	}
+
+	t.Run("back up the full list before deleting", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		backup := &backupBuffer{}
+		fake := newFakeLocalDNS(records...)
+		c := newUnitTestClient(t, Config{BackupBeforeDelete: func() (io.WriteCloser, error) {
+			return backup, nil
+		}}, fake.ServeHTTP)
+
+		_, err := c.LocalDNS.DeleteMany(context.Background(), []string{"a.lan"}, DeleteManyOptions{})
+		require.NoError(t, err)
+
+		assert.True(t, backup.closed)
+		assert.JSONEq(t, `{"version":1,"records":[{"domain":"a.lan","ip":"10.0.0.1"},{"domain":"b.lan","ip":"10.0.0.2"}]}`, backup.String())
+		assert.Equal(t, DNSRecordList{records[1]}, fake.list())
+	})
+
+	t.Run("abort without deleting when the backup fails", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(records...)
+		c := newUnitTestClient(t, Config{BackupBeforeDelete: func() (io.WriteCloser, error) {
+			return nil, errors.New("disk full")
+		}}, fake.ServeHTTP)
+
+		_, err := c.LocalDNS.DeleteByIP(context.Background(), "10.0.0.1")
+
+		assert.ErrorIs(t, err, ErrBackupFailed)
+		assert.Equal(t, DNSRecordList(records), fake.list())
+	})
+
+	t.Run("back up before upsert, renumber and normalize delete", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		var backups int
+		newClient := func(records ...DNSRecord) *Client {
+			return newUnitTestClient(t, Config{BackupBeforeDelete: func() (io.WriteCloser, error) {
+				backups++
+				return nil, errors.New("disk full")
+			}}, newFakeLocalDNS(records...).ServeHTTP)
+		}
+		ctx := context.Background()
+
+		_, _, err := newClient(records[0]).LocalDNS.Upsert(ctx, DNSRecordList{{Domain: "a.lan", IP: "10.0.0.5"}})
+		assert.ErrorIs(t, err, ErrBackupFailed)
+
+		_, err = newClient(records...).LocalDNS.RenumberSubnet(ctx, "10.0.0.0/24", "10.1.0.0/24")
+		assert.ErrorIs(t, err, ErrBackupFailed)
+
+		_, err = newClient(DNSRecord{Domain: "Host.lan", IP: "10.0.0.1"}).LocalDNS.NormalizeExisting(ctx)
+		assert.ErrorIs(t, err, ErrBackupFailed)
+
+		assert.Equal(t, 3, backups)
+	})
+
+	t.Run("skip the backup when nothing is deleted", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{BackupBeforeDelete: func() (io.WriteCloser, error) {
+			t.Error("unexpected backup")
+			return &backupBuffer{}, nil
+		}}, newFakeLocalDNS(records...).ServeHTTP)
+
+		_, err := c.LocalDNS.Reconcile(context.Background(), DNSRecordList{records[0]}, ReconcileOptions{AllowDelete: true, DryRun: true})
+		require.NoError(t, err)
+	})
+}
+
+func TestLocalDNSRestoreJSON(t *testing.T) {
+	backup := `{"version":1,"records":[{"domain":"a.lan","ip":"10.0.0.1"},{"domain":"b.lan","ip":"10.0.0.2"}]}`
+
+	t.Run("merge keeps records missing from the backup", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(DNSRecord{Domain: "a.lan", IP: "10.0.0.1"}, DNSRecord{Domain: "extra.lan", IP: "10.0.0.9"})
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		require.NoError(t, c.LocalDNS.RestoreJSON(context.Background(), strings.NewReader(backup), RestoreMerge))
+
+		assert.ElementsMatch(t, DNSRecordList{
+			{Domain: "a.lan", IP: "10.0.0.1"},
+			{Domain: "b.lan", IP: "10.0.0.2"},
+			{Domain: "extra.lan", IP: "10.0.0.9"},
+		}, fake.list())
+	})
+
+	t.Run("replace makes the server match the backup", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(DNSRecord{Domain: "extra.lan", IP: "10.0.0.9"})
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		require.NoError(t, c.LocalDNS.RestoreJSON(context.Background(), strings.NewReader(backup), RestoreReplace))
+
+		assert.ElementsMatch(t, DNSRecordList{
+			{Domain: "a.lan", IP: "10.0.0.1"},
+			{Domain: "b.lan", IP: "10.0.0.2"},
+		}, fake.list())
+	})
+
+	t.Run("round trip an export", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		source := newUnitTestClient(t, Config{}, newFakeLocalDNS(DNSRecord{Domain: "a.lan", IP: "10.0.0.1"}).ServeHTTP)
+		fake := newFakeLocalDNS()
+		target := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		var buf bytes.Buffer
+		require.NoError(t, source.LocalDNS.ExportJSON(context.Background(), &buf))
+		require.NoError(t, target.LocalDNS.RestoreJSON(context.Background(), &buf, RestoreReplace))
+
+		assert.Equal(t, DNSRecordList{{Domain: "a.lan", IP: "10.0.0.1"}}, fake.list())
+	})
+
+	t.Run("restore records stored with a trailing dot", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		source := newUnitTestClient(t, Config{}, newFakeLocalDNS(DNSRecord{Domain: "host.lan.", IP: "10.0.0.1"}).ServeHTTP)
+		fake := newFakeLocalDNS()
+		target := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		var buf bytes.Buffer
+		require.NoError(t, source.LocalDNS.ExportJSON(context.Background(), &buf))
+		require.NoError(t, target.LocalDNS.RestoreJSON(context.Background(), &buf, RestoreMerge))
+
+		assert.Equal(t, DNSRecordList{{Domain: "host.lan", IP: "10.0.0.1"}}, fake.list())
+	})
+
+	t.Run("refuse another schema version without changes", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(DNSRecord{Domain: "extra.lan", IP: "10.0.0.9"})
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		err := c.LocalDNS.RestoreJSON(context.Background(), strings.NewReader(`{"records":[]}`), RestoreReplace)
+
+		assert.ErrorIs(t, err, ErrIncompatibleBackup)
+		assert.Equal(t, DNSRecordList{{Domain: "extra.lan", IP: "10.0.0.9"}}, fake.list())
+	})
+
+	t.Run("refuse invalid records without changes", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		err := c.LocalDNS.RestoreJSON(context.Background(), strings.NewReader(
+			`{"version":1,"records":[{"domain":"a.lan","ip":"10.0.0.1"},{"domain":"b.lan","ip":"bad"}]}`), RestoreMerge)
+
+		assert.ErrorIs(t, err, ErrInvalidIP)
+		assert.Empty(t, fake.list())
+	})
+}
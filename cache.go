@@ -0,0 +1,63 @@
+package pihole
+
+import (
+	"sync"
+	"time"
+)
+
+// recordCache memoizes the custom DNS record list for a limited time
+type recordCache struct {
+	ttl   time.Duration
+	clock Clock
+
+	mu         sync.Mutex
+	generation uint64
+	list       DNSRecordList
+	expires    time.Time
+}
+
+// get returns a copy of the cached list if it is still fresh, along with the generation a
+// fetch started now must present to set
+func (rc *recordCache) get() (DNSRecordList, uint64, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.list == nil || !rc.clock.Now().Before(rc.expires) {
+		return nil, rc.generation, false
+	}
+
+	return append(DNSRecordList{}, rc.list...), rc.generation, true
+}
+
+// set stores a fetched list unless the cache was invalidated since the fetch started
+func (rc *recordCache) set(list DNSRecordList, generation uint64) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if generation != rc.generation {
+		return
+	}
+
+	rc.list = append(DNSRecordList{}, list...)
+	rc.expires = rc.clock.Now().Add(rc.ttl)
+}
+
+func (rc *recordCache) invalidate() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.generation++
+	rc.list = nil
+}
+
+// InvalidateCache drops any cached DNS records so the next read goes to the server. With
+// Config.ShareListRequests, later reads also stop joining a list request already in flight,
+// whose result may predate the change. It is a no-op when neither option is set.
+func (c *Client) InvalidateCache() {
+	if c.cache != nil {
+		c.cache.invalidate()
+	}
+	if c.listFlight != nil {
+		c.listFlight.Forget(listFlightKey)
+	}
+}
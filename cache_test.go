@@ -0,0 +1,109 @@
+package pihole
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	newCountingClient := func(t *testing.T, fake *fakeLocalDNS, gets *int32) *Client {
+		return newUnitTestClient(t, Config{CacheTTL: time.Minute}, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("action") == "get" {
+				atomic.AddInt32(gets, 1)
+			}
+			fake.ServeHTTP(w, r)
+		})
+	}
+
+	t.Run("serve repeated lists from the cache", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		var gets int32
+		c := newCountingClient(t, newFakeLocalDNS(DNSRecord{Domain: "a.lan", IP: "10.0.0.1"}), &gets)
+
+		for i := 0; i < 3; i++ {
+			list, err := c.LocalDNS.List(context.Background())
+			require.NoError(t, err)
+			assert.Len(t, list, 1)
+		}
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&gets))
+	})
+
+	t.Run("invalidate on mutation", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		var gets int32
+		c := newCountingClient(t, newFakeLocalDNS(), &gets)
+		ctx := context.Background()
+
+		_, err := c.LocalDNS.List(ctx)
+		require.NoError(t, err)
+
+		_, err = c.LocalDNS.Create(ctx, "a.lan", "10.0.0.1")
+		require.NoError(t, err)
+
+		list, err := c.LocalDNS.List(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, DNSRecordList{{Domain: "a.lan", IP: "10.0.0.1"}}, list)
+	})
+
+	t.Run("invalidate on failed mutation", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		var gets int32
+		c := newCountingClient(t, newFakeLocalDNS(), &gets)
+		ctx := context.Background()
+
+		_, err := c.LocalDNS.List(ctx)
+		require.NoError(t, err)
+
+		err = c.LocalDNS.(*localDNS).deleteRecord(ctx, DNSRecord{Domain: "missing.lan", IP: "10.0.0.1"})
+		require.Error(t, err)
+
+		_, err = c.LocalDNS.List(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&gets))
+	})
+
+	t.Run("invalidate manually", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		var gets int32
+		c := newCountingClient(t, newFakeLocalDNS(), &gets)
+		ctx := context.Background()
+
+		_, err := c.LocalDNS.List(ctx)
+		require.NoError(t, err)
+
+		c.InvalidateCache()
+
+		_, err = c.LocalDNS.List(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&gets))
+	})
+
+	t.Run("ignore lists fetched before an invalidation", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		rc := &recordCache{ttl: time.Minute, clock: realClock{}}
+
+		_, generation, _ := rc.get()
+		rc.invalidate()
+		rc.set(DNSRecordList{{Domain: "stale.lan", IP: "10.0.0.1"}}, generation)
+
+		_, _, ok := rc.get()
+		assert.False(t, ok)
+	})
+}
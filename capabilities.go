@@ -0,0 +1,68 @@
+package pihole
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Capabilities reports which features of this client the connected Pi-hole supports
+type Capabilities struct {
+	// WebVersion is the web interface version the capabilities were derived from
+	WebVersion string
+
+	CustomDNS   bool
+	CustomCNAME bool
+	DomainLists bool
+	RegexLists  bool
+	Network     bool
+}
+
+// Capabilities detects the features supported by the connected Pi-hole from its web
+// interface version. Development builds without a release version are assumed to
+// support everything.
+func (c *Client) Capabilities(ctx context.Context) (*Capabilities, error) {
+	versions, err := c.Version.Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect capabilities: %w", err)
+	}
+	if versions == nil {
+		return nil, errors.New("failed to detect capabilities: missing versions")
+	}
+
+	atLeast := func(major, minor int) bool {
+		v, ok := parseReleaseVersion(versions.WebCurrent)
+		return !ok || v[0] > major || (v[0] == major && v[1] >= minor)
+	}
+
+	return &Capabilities{
+		WebVersion:  versions.WebCurrent,
+		CustomDNS:   atLeast(5, 11),
+		CustomCNAME: atLeast(5, 11),
+		DomainLists: atLeast(5, 0),
+		RegexLists:  atLeast(5, 0),
+		Network:     atLeast(5, 0),
+	}, nil
+}
+
+// parseReleaseVersion parses a "v5.11.4" style version into its major and minor numbers
+func parseReleaseVersion(version string) ([2]int, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return [2]int{}, false
+	}
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return [2]int{}, false
+	}
+
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return [2]int{}, false
+	}
+
+	return [2]int{major, minor}, true
+}
@@ -0,0 +1,95 @@
+package pihole
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapabilities(t *testing.T) {
+	versionServer := func(web string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"core_current":"v5.10","web_current":%q,"FTL_current":"v5.15"}`, web)
+		}
+	}
+
+	t.Run("disable custom records before 5.11", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, versionServer("v5.10.2"))
+
+		caps, err := c.Capabilities(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, &Capabilities{
+			WebVersion:  "v5.10.2",
+			DomainLists: true,
+			RegexLists:  true,
+			Network:     true,
+		}, caps)
+	})
+
+	t.Run("enable everything from 5.11", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, versionServer("v5.12"))
+
+		caps, err := c.Capabilities(context.Background())
+		require.NoError(t, err)
+
+		assert.True(t, caps.CustomDNS)
+		assert.True(t, caps.CustomCNAME)
+	})
+
+	t.Run("assume development builds support everything", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, versionServer("vDev"))
+
+		caps, err := c.Capabilities(context.Background())
+		require.NoError(t, err)
+
+		assert.True(t, caps.CustomDNS)
+	})
+
+	t.Run("error on a null versions body", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `null`)
+		})
+
+		_, err := c.Capabilities(context.Background())
+		assert.Error(t, err)
+	})
+
+	t.Run("error on a nil versions result", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, versionServer("v5.21"))
+		c.Version = nilVersion{}
+
+		_, err := c.Capabilities(context.Background())
+		assert.Error(t, err)
+	})
+
+	t.Run("detect capabilities", func(t *testing.T) {
+		isAcceptance(t)
+
+		c := newTestClient(t)
+
+		caps, err := c.Capabilities(context.Background())
+		require.NoError(t, err)
+
+		assert.True(t, caps.CustomDNS)
+	})
+}
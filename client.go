@@ -1,14 +1,22 @@
 package pihole
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
+	"golang.org/x/sync/singleflight"
 )
 
 type Config struct {
@@ -16,28 +24,147 @@ type Config struct {
 	APIToken   string
 	HttpClient *http.Client
 	Headers    http.Header
+
+	// APITokenFile, instead of APIToken, names a file holding the API token, such as a
+	// mounted secret. Surrounding whitespace is trimmed and the file is read again whenever
+	// it changes, so a rotated token is used without creating a new client.
+	APITokenFile string
+
+	// CheckRedirect decides whether a redirect is followed, like http.Client.CheckRedirect.
+	// Defaults to SameHostRedirectPolicy so the API token never follows a redirect to another
+	// host. When HttpClient is set, its own policy is kept unless CheckRedirect is also set.
+	CheckRedirect func(req *http.Request, via []*http.Request) error
+
+	// OperationTimeout bounds each bulk operation (CreateMany, DeleteMany, ...) as a whole,
+	// on top of any deadline already carried by the caller's context. Zero means no bound.
+	// Like cancellation, it is checked between records: the record in flight is finished.
+	OperationTimeout time.Duration
+
+	// RequestIDHeader, when set, names the header carrying the correlation ID attached to
+	// the request context with WithRequestID, e.g. "X-Request-ID"
+	RequestIDHeader string
+
+	// AuditFunc, when set, is called after every create, delete or update sent to Pi-hole,
+	// whether or not it succeeded
+	AuditFunc func(AuditEvent)
+
+	// SuccessChecker, when set, replaces the success/FTLnotrunning check of mutating
+	// actions for servers that signal success differently. It receives the raw response
+	// body and returns nil when the action succeeded.
+	SuccessChecker func(body []byte) error
+
+	// BackupBeforeDelete, when set, is called before DeleteMany, DeleteByIP, Reconcile,
+	// Upsert, RenumberSubnet and NormalizeExisting delete records. The full record list is
+	// written to the returned writer in the ExportJSON format and the writer is closed; if
+	// any step fails, the operation is aborted with ErrBackupFailed before changing anything.
+	BackupBeforeDelete func() (io.WriteCloser, error)
+
+	// OnRetry, when set, is called before the default transport waits to retry a request,
+	// with the retry number starting at 1, the error or status that caused it and the wait.
+	// Ignored when HttpClient is set.
+	OnRetry func(attempt int, err error, next time.Duration)
+
+	// MaxIdleConns sets how many idle connections to Pi-hole the default transport keeps
+	// for reuse. Defaults to DefaultMaxIdleConns; ignored when HttpClient is set.
+	MaxIdleConns int
+
+	// CacheTTL, when set, caches the custom DNS record list for this long. Every DNS
+	// create or delete through the client invalidates it, whether or not it succeeded.
+	CacheTTL time.Duration
+
+	// ShareListRequests, when set, lets concurrent DNS record list reads that find no fresh
+	// cache share one in-flight request instead of each sending their own. A caller can
+	// then receive a list fetched slightly before its own call started, but never one that
+	// started before a create or delete made through the client.
+	ShareListRequests bool
+
+	// Resolver, when set, is used by VerifyResolution and VerifyAll when they are given no
+	// resolver, e.g. one whose Dial speaks DNS over HTTPS
+	Resolver *net.Resolver
+
+	// Clock, when set, replaces real time, e.g. to fast-forward the cache TTL in tests
+	Clock Clock
+
+	// MaxResponseBytes caps how much of a response body is read before decoding fails
+	// with ErrResponseTooLarge. Defaults to DefaultMaxResponseBytes when unset.
+	MaxResponseBytes int64
 }
 
+// DefaultMaxIdleConns is the idle connection pool size used when Config.MaxIdleConns is
+// unset. The client talks to a single host, so the whole pool serves that host.
+const DefaultMaxIdleConns = 16
+
+// defaultIdleConnTimeout is how long the default transport keeps an idle connection open
+const defaultIdleConnTimeout = 90 * time.Second
+
+// DefaultMaxResponseBytes is the response body limit used when Config.MaxResponseBytes is unset
+const DefaultMaxResponseBytes int64 = 5 << 20
+
+// Client is a Pi-hole API client. Each feature is exposed as an interface field, so code
+// depending on a *Client can be tested by setting those fields to fakes, e.g.
+// &pihole.Client{LocalDNS: fakeLocalDNS}, without a reachable server.
+//
+// A Client returned by New is safe for concurrent use by multiple goroutines. Its
+// configuration is not modified after construction; the optional record cache and token
+// file are guarded by their own mutexes.
 type Client struct {
-	baseURL    string
-	apiToken   string
-	headers    http.Header
-	http       *http.Client
-	LocalDNS   LocalDNS
+	baseURL          string
+	apiToken         string
+	tokenFile        *tokenFile
+	headers          http.Header
+	requestIDHeader  string
+	http             *http.Client
+	maxResponseBytes int64
+	operationTimeout time.Duration
+	auditFunc        func(AuditEvent)
+	successChecker   func(body []byte) error
+	backupFunc       func() (io.WriteCloser, error)
+	cache            *recordCache
+	listFlight       *singleflight.Group
+	clock            Clock
+	resolver         *net.Resolver
+
+	// LocalDNS manages custom DNS records
+	LocalDNS LocalDNS
+
+	// LocalCNAME manages custom CNAME records
 	LocalCNAME LocalCNAME
-	AdBlocker  AdBlocker
-	Version    Version
+
+	// DomainList manages the exact and regex allow and deny lists
+	DomainList DomainList
+
+	// AdBlocker reads and toggles the ad blocking status
+	AdBlocker AdBlocker
+
+	// Version reports the server component versions
+	Version Version
+
+	// Network lists the devices seen on the network
+	Network Network
+
+	// Stats reads FTL statistics
+	Stats Stats
+
+	// Queries searches the long-term query database
+	Queries Queries
 }
 
 // New returns a new Pi-hole client
 func New(config Config) (*Client, error) {
 	baseURL := strings.TrimSuffix(config.BaseURL, "/")
 
-	baseURL = fmt.Sprintf("%s/admin/api.php", baseURL)
+	baseURL = fmt.Sprintf("%s/admin", baseURL)
 
-	httpClient := retryablehttp.NewClient().StandardClient()
+	var httpClient *http.Client
 	if config.HttpClient != nil {
 		httpClient = config.HttpClient
+		if config.CheckRedirect != nil {
+			custom := *config.HttpClient
+			custom.CheckRedirect = config.CheckRedirect
+			httpClient = &custom
+		}
+	} else {
+		httpClient = newHTTPClient(config)
 	}
 
 	headers := make(http.Header)
@@ -49,17 +176,61 @@ func New(config Config) (*Client, error) {
 		}
 	}
 
+	maxResponseBytes := DefaultMaxResponseBytes
+	if config.MaxResponseBytes > 0 {
+		maxResponseBytes = config.MaxResponseBytes
+	}
+
+	var tokenFile *tokenFile
+	if config.APITokenFile != "" {
+		if config.APIToken != "" {
+			return nil, fmt.Errorf("%w: APIToken and APITokenFile are mutually exclusive", ErrClientValidation)
+		}
+
+		tf, err := newTokenFile(config.APITokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrClientValidation, err)
+		}
+		tokenFile = tf
+	}
+
+	var clock Clock = realClock{}
+	if config.Clock != nil {
+		clock = config.Clock
+	}
+
 	client := &Client{
-		baseURL:  baseURL,
-		apiToken: config.APIToken,
-		http:     httpClient,
-		headers:  headers,
+		baseURL:          baseURL,
+		apiToken:         config.APIToken,
+		tokenFile:        tokenFile,
+		http:             httpClient,
+		headers:          headers,
+		requestIDHeader:  config.RequestIDHeader,
+		maxResponseBytes: maxResponseBytes,
+		operationTimeout: config.OperationTimeout,
+		auditFunc:        config.AuditFunc,
+		successChecker:   config.SuccessChecker,
+		backupFunc:       config.BackupBeforeDelete,
+		clock:            clock,
+		resolver:         config.Resolver,
+	}
+
+	if config.CacheTTL > 0 {
+		client.cache = &recordCache{ttl: config.CacheTTL, clock: clock}
+	}
+
+	if config.ShareListRequests {
+		client.listFlight = &singleflight.Group{}
 	}
 
 	client.LocalDNS = &localDNS{client: client}
 	client.LocalCNAME = &localCNAME{client: client}
+	client.DomainList = &domainList{client: client}
 	client.AdBlocker = &adBlocker{client: client}
 	client.Version = &version{client: client}
+	client.Network = &network{client: client}
+	client.Stats = &stats{client: client}
+	client.Queries = &queries{client: client}
 
 	if err := client.validate(); err != nil {
 		return nil, err
@@ -68,25 +239,145 @@ func New(config Config) (*Client, error) {
 	return client, nil
 }
 
-var ErrClientValidation = errors.New("invalid client configuration")
+var (
+	// ErrNotFound is wrapped by every subsystem's not found error
+	ErrNotFound = errors.New("not found")
+
+	ErrClientValidation = errors.New("invalid client configuration")
+	ErrResponseTooLarge = errors.New("response body exceeds size limit")
+	ErrRedirectRefused  = errors.New("redirect refused")
+	ErrUnexpectedStatus = errors.New("unexpected HTTP status")
+	ErrFTLNotRunning    = errors.New("pihole-FTL is not running")
+	ErrNotSupported     = errors.New("not supported by the server")
+)
+
+// newHTTPClient returns the retrying HTTP client used when no Config.HttpClient is given
+func newHTTPClient(config Config) *http.Client {
+	policy := SameHostRedirectPolicy
+	if config.CheckRedirect != nil {
+		policy = config.CheckRedirect
+	}
+
+	maxIdleConns := DefaultMaxIdleConns
+	if config.MaxIdleConns > 0 {
+		maxIdleConns = config.MaxIdleConns
+	}
+
+	retryClient := retryablehttp.NewClient()
+	if transport, ok := retryClient.HTTPClient.Transport.(*http.Transport); ok {
+		transport.MaxIdleConns = maxIdleConns
+		transport.MaxIdleConnsPerHost = maxIdleConns
+		transport.IdleConnTimeout = defaultIdleConnTimeout
+	}
+	retryClient.HTTPClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		err := policy(req, via)
+		if err != nil && err != http.ErrUseLastResponse {
+			return redirectPolicyError{err: err}
+		}
+		return err
+	}
+	retryClient.CheckRetry = func(ctx context.Context, res *http.Response, err error) (bool, error) {
+		var redirectErr redirectPolicyError
+		if errors.As(err, &redirectErr) {
+			return false, err
+		}
+		retry, checkErr := retryablehttp.DefaultRetryPolicy(ctx, res, err)
+		if retry && config.OnRetry != nil {
+			notifyRetry(ctx, retryClient, res, err, config.OnRetry)
+		}
+		return retry, checkErr
+	}
+
+	// The retrying transport already applied the policy, so a redirect reaching the outer
+	// client was one the policy chose to return rather than follow
+	client := retryClient.StandardClient()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return client
+}
+
+// SameHostRedirectPolicy follows up to 10 redirects as long as they stay on the host of
+// the original request and do not downgrade https to http, refusing others with
+// ErrRedirectRefused
+func SameHostRedirectPolicy(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return fmt.Errorf("%w: stopped after 10 redirects", ErrRedirectRefused)
+	}
+
+	if from := via[0].URL.Hostname(); req.URL.Hostname() != from {
+		return fmt.Errorf("%w: %s redirected to another host %s", ErrRedirectRefused, from, req.URL.Hostname())
+	}
+
+	if via[0].URL.Scheme == "https" && req.URL.Scheme != "https" {
+		return fmt.Errorf("%w: %s redirected from https to %s", ErrRedirectRefused, req.URL.Hostname(), req.URL.Scheme)
+	}
+
+	return nil
+}
+
+// redirectPolicyError marks errors returned by the redirect policy so they are not retried
+type redirectPolicyError struct {
+	err error
+}
+
+func (e redirectPolicyError) Error() string { return e.err.Error() }
+
+func (e redirectPolicyError) Unwrap() error { return e.err }
 
 func (c Client) validate() error {
-	if c.apiToken == "" {
+	if c.token() == "" {
 		return fmt.Errorf("%w: apiToken is empty", ErrClientValidation)
 	}
-	if c.baseURL == "/admin/api.php" {
+	if c.baseURL == "/admin" {
 		return fmt.Errorf("%w: baseURL is empty", ErrClientValidation)
 	}
 
 	return nil
 }
 
+// Request returns an authenticated request to api.php carrying vals as its query
 func (c Client) Request(ctx context.Context, vals url.Values) (*http.Request, error) {
-	vals.Set("auth", c.apiToken)
+	return c.request(ctx, "api.php", vals)
+}
+
+// redactedToken replaces the API token in URLs returned by BuildURL
+const redactedToken = "REDACTED"
 
-	url := fmt.Sprintf("%s?%s", c.baseURL, vals.Encode())
+// BuildURL returns the URL Request would use for vals, with the API token replaced by
+// REDACTED, without sending anything. vals is not modified.
+func (c Client) BuildURL(vals url.Values) (string, error) {
+	u := c.requestURL("api.php", copyValues(vals), redactedToken)
+	if _, err := url.Parse(u); err != nil {
+		return "", err
+	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	return u, nil
+}
+
+// requestURL formats the URL of a request to the given admin endpoint, setting the auth
+// parameter of vals to token
+func (c Client) requestURL(endpoint string, vals url.Values, token string) string {
+	vals.Set("auth", token)
+
+	return fmt.Sprintf("%s/%s?%s", c.baseURL, endpoint, vals.Encode())
+}
+
+func copyValues(vals url.Values) url.Values {
+	copied := make(url.Values, len(vals))
+	for key, values := range vals {
+		copied[key] = append([]string(nil), values...)
+	}
+
+	return copied
+}
+
+// request returns an authenticated request to the given admin endpoint, e.g. api_db.php
+func (c Client) request(ctx context.Context, endpoint string, vals url.Values) (*http.Request, error) {
+	url := c.requestURL(endpoint, vals, c.token())
+
+	req, err := http.NewRequestWithContext(withRetryState(ctx), "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -95,5 +386,171 @@ func (c Client) Request(ctx context.Context, vals url.Values) (*http.Request, er
 		req.Header[key] = header
 	}
 
+	if id, ok := RequestIDFromContext(ctx); ok && c.requestIDHeader != "" {
+		req.Header.Set(c.requestIDHeader, id)
+	}
+
 	return req, nil
 }
+
+// operationContext derives the context bounding a whole bulk operation
+func (c Client) operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.operationTimeout > 0 {
+		return context.WithTimeout(ctx, c.operationTimeout)
+	}
+
+	return context.WithCancel(ctx)
+}
+
+// recordTimeout bounds the requests made for a single record of a bulk operation
+const recordTimeout = 30 * time.Second
+
+// recordContext derives the context for the requests of one record within a bulk operation.
+// It keeps ctx's values but not its cancellation or deadline, so cancelling ctx stops the
+// operation at the next record boundary instead of abandoning a request the server may
+// already have applied. recordTimeout still bounds the record.
+func recordContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(detachedContext{parent: ctx}, recordTimeout)
+}
+
+// detachedContext carries the values of its parent but is never cancelled
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (d detachedContext) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
+
+// decode parses a JSON response body into v, reading at most the configured response size
+// limit. api.php answers actions it does not implement with an empty array, which is
+// reported as ErrNotSupported.
+func (c Client) decode(body io.Reader, v interface{}) error {
+	var raw json.RawMessage
+	if err := json.NewDecoder(&limitedReader{r: body, n: c.maxResponseBytes}).Decode(&raw); err != nil {
+		return err
+	}
+
+	if bytes.Equal(raw, []byte("[]")) {
+		return fmt.Errorf("%w: empty response from the Pi-hole v5 API, the action needs a newer Pi-hole or an API token it accepts", ErrNotSupported)
+	}
+
+	return json.Unmarshal(raw, v)
+}
+
+// decodeError wraps an error from decode with the feature whose response was being read,
+// so an ErrNotSupported names what the server lacks
+func decodeError(feature string, err error) error {
+	if errors.Is(err, ErrNotSupported) {
+		return fmt.Errorf("%s: %w", feature, err)
+	}
+
+	return fmt.Errorf("failed to parse %s body: %w", feature, err)
+}
+
+// apiResponse is the envelope returned by Pi-hole's mutating actions
+type apiResponse struct {
+	Success       bool   `json:"success"`
+	Message       string `json:"message"`
+	FTLNotRunning bool   `json:"FTLnotrunning"`
+}
+
+// APIError is returned when Pi-hole reports that an action did not succeed
+type APIError struct {
+	// Message is the server message with HTML markup and entities removed
+	Message string
+
+	// RawMessage is the message exactly as returned by the server
+	RawMessage string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("pihole: %s", e.Message)
+}
+
+// decodeAndCheck decodes a mutating action's response envelope into out and returns an
+// error unless the server answered with a 2xx status, a running FTL and success set, or
+// the configured SuccessChecker accepted the body
+func (c Client) decodeAndCheck(res *http.Response, out *apiResponse) error {
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("%w: %s", ErrUnexpectedStatus, res.Status)
+	}
+
+	if c.successChecker != nil {
+		body, err := io.ReadAll(&limitedReader{r: res.Body, n: c.maxResponseBytes})
+		if err != nil {
+			return fmt.Errorf("failed to read response body: %w", err)
+		}
+		if err := c.successChecker(body); err != nil {
+			return err
+		}
+
+		// The envelope is informational once the checker accepted the body
+		_ = json.Unmarshal(body, out)
+		return nil
+	}
+
+	if err := c.decode(res.Body, out); err != nil {
+		return fmt.Errorf("failed to parse response body: %w", err)
+	}
+
+	if out.FTLNotRunning {
+		return ErrFTLNotRunning
+	}
+
+	if !out.Success {
+		return &APIError{Message: sanitizeMessage(out.Message), RawMessage: out.Message}
+	}
+
+	return nil
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// sanitizeMessage turns an HTML server message into plain text on a single line
+func sanitizeMessage(message string) string {
+	text := html.UnescapeString(htmlTag.ReplaceAllString(message, " "))
+
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// limitedReader behaves like io.LimitReader but fails with ErrResponseTooLarge instead of
+// reporting EOF when the underlying reader holds more than n bytes
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		var probe [1]byte
+		n, err := l.r.Read(probe[:])
+		if n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// maxDrainBytes bounds how much of an unread response body is discarded to keep the
+// connection reusable. Larger leftovers are not worth reading; the connection is dropped.
+const maxDrainBytes = 64 << 10
+
+// drainAndClose discards whatever the decoder left unread, such as trailing whitespace or a
+// stray second JSON object, then closes the body so the connection can be reused
+func drainAndClose(body io.ReadCloser) {
+	_, _ = io.CopyN(io.Discard, body, maxDrainBytes)
+	body.Close()
+}
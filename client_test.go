@@ -1,11 +1,19 @@
 package pihole
 
 import (
+	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/hashicorp/go-retryablehttp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -46,6 +54,341 @@ func TestClientValidation(t *testing.T) {
 	})
 }
 
+func TestClientResponseLimit(t *testing.T) {
+	t.Run("error on response body over the limit", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{MaxResponseBytes: 64}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"data":[["%s","127.0.0.1"]]}`, strings.Repeat("a", 128))
+		})
+
+		_, err := c.LocalDNS.List(context.Background())
+		assert.ErrorIs(t, err, ErrResponseTooLarge)
+	})
+
+	t.Run("no error on response body within the limit", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{MaxResponseBytes: 64}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"data":[["a.lan","127.0.0.1"]]}`)
+		})
+
+		records, err := c.LocalDNS.List(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, DNSRecordList{{Domain: "a.lan", IP: "127.0.0.1"}}, records)
+	})
+}
+
+func TestClientBuildURL(t *testing.T) {
+	t.Run("match the request URL with the token redacted", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c, err := New(Config{BaseURL: "http://pi.hole/", APIToken: "secret"})
+		require.NoError(t, err)
+
+		vals := url.Values{"customdns": []string{"true"}, "action": []string{"get"}}
+
+		built, err := c.BuildURL(vals)
+		require.NoError(t, err)
+		assert.Equal(t, "http://pi.hole/admin/api.php?action=get&auth=REDACTED&customdns=true", built)
+		assert.False(t, vals.Has("auth"))
+
+		req, err := c.Request(context.Background(), vals)
+		require.NoError(t, err)
+		assert.Equal(t, strings.Replace(built, "REDACTED", "secret", 1), req.URL.String())
+	})
+}
+
+func TestClientConnectionPool(t *testing.T) {
+	transportOf := func(t *testing.T, config Config) *http.Transport {
+		rt, ok := newHTTPClient(config).Transport.(*retryablehttp.RoundTripper)
+		require.True(t, ok)
+
+		transport, ok := rt.Client.HTTPClient.Transport.(*http.Transport)
+		require.True(t, ok)
+
+		return transport
+	}
+
+	t.Run("pool connections to the single host by default", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		transport := transportOf(t, Config{})
+		assert.Equal(t, DefaultMaxIdleConns, transport.MaxIdleConnsPerHost)
+		assert.Equal(t, DefaultMaxIdleConns, transport.MaxIdleConns)
+		assert.Equal(t, defaultIdleConnTimeout, transport.IdleConnTimeout)
+	})
+
+	t.Run("apply the configured pool size", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		transport := transportOf(t, Config{MaxIdleConns: 4})
+		assert.Equal(t, 4, transport.MaxIdleConnsPerHost)
+		assert.Equal(t, 4, transport.MaxIdleConns)
+	})
+}
+
+func TestClientRedirectPolicy(t *testing.T) {
+	t.Run("error on redirect to another host", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Errorf("token leaked to redirect target: %s", r.URL)
+		}))
+		defer other.Close()
+
+		target := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, target+r.URL.RequestURI(), http.StatusFound)
+		})
+
+		_, err := c.LocalDNS.List(context.Background())
+		assert.ErrorIs(t, err, ErrRedirectRefused)
+	})
+
+	t.Run("follow redirect on the same host", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/admin/api.php" {
+				http.Redirect(w, r, "/admin/api.php/?"+r.URL.RawQuery, http.StatusMovedPermanently)
+				return
+			}
+			fmt.Fprint(w, `{"data":[]}`)
+		})
+
+		_, err := c.LocalDNS.List(context.Background())
+		assert.NoError(t, err)
+	})
+
+	t.Run("error on redirect from https to http", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		from := httptest.NewRequest(http.MethodGet, "https://pi.hole/admin/api.php", nil)
+
+		err := SameHostRedirectPolicy(httptest.NewRequest(http.MethodGet, "http://pi.hole/admin/api.php", nil), []*http.Request{from})
+		assert.ErrorIs(t, err, ErrRedirectRefused)
+
+		err = SameHostRedirectPolicy(httptest.NewRequest(http.MethodGet, "https://pi.hole/admin/", nil), []*http.Request{from})
+		assert.NoError(t, err)
+	})
+
+	t.Run("use custom redirect policy", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		errNoRedirects := errors.New("no redirects")
+		c := newUnitTestClient(t, Config{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error { return errNoRedirects },
+		}, func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/elsewhere", http.StatusFound)
+		})
+
+		_, err := c.LocalDNS.List(context.Background())
+		assert.ErrorIs(t, err, errNoRedirects)
+	})
+
+	t.Run("return the redirect when the policy uses the last response", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse },
+		}, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/elsewhere" {
+				t.Errorf("redirect followed: %s", r.URL)
+			}
+			http.Redirect(w, r, "/elsewhere?"+r.URL.RawQuery, http.StatusFound)
+		})
+
+		_, err := c.LocalDNS.List(context.Background())
+		assert.Error(t, err)
+	})
+}
+
+func TestClientNotSupported(t *testing.T) {
+	t.Run("error on empty array response", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `[]`)
+		})
+
+		_, err := c.LocalCNAME.List(context.Background())
+		require.ErrorIs(t, err, ErrNotSupported)
+		assert.Contains(t, err.Error(), "custom CNAME list")
+		assert.Contains(t, err.Error(), "Pi-hole v5")
+	})
+}
+
+type trackingBody struct {
+	*strings.Reader
+	closed bool
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestClientTrailingData(t *testing.T) {
+	t.Run("decode the first object", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"data":[["a.lan","10.0.0.1"]]}`+"\n\n"+`{"data":[]}`)
+		})
+
+		list, err := c.LocalDNS.List(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, DNSRecordList{{Domain: "a.lan", IP: "10.0.0.1"}}, list)
+	})
+
+	t.Run("drain the body before closing", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		body := &trackingBody{Reader: strings.NewReader(`{"success":true}  {"stray":1}` + "\n")}
+		c := &Client{maxResponseBytes: DefaultMaxResponseBytes}
+
+		var out apiResponse
+		require.NoError(t, c.decode(body, &out))
+		assert.True(t, out.Success)
+
+		drainAndClose(body)
+		assert.Zero(t, body.Len())
+		assert.True(t, body.closed)
+	})
+}
+
+func TestClientDecodeAndCheck(t *testing.T) {
+	c := &Client{maxResponseBytes: DefaultMaxResponseBytes}
+
+	respond := func(status int, body string) *http.Response {
+		rec := httptest.NewRecorder()
+		rec.WriteHeader(status)
+		fmt.Fprint(rec, body)
+		return rec.Result()
+	}
+
+	t.Run("no error on success", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		var out apiResponse
+		err := c.decodeAndCheck(respond(http.StatusOK, `{"success":true,"message":"done"}`), &out)
+
+		require.NoError(t, err)
+		assert.Equal(t, "done", out.Message)
+	})
+
+	t.Run("API error on failure", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		var out apiResponse
+		err := c.decodeAndCheck(respond(http.StatusOK, `{"success":false,"message":"nope"}`), &out)
+
+		var apiErr *APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, "nope", apiErr.Message)
+	})
+
+	t.Run("API error with sanitized message", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		raw := `Domain <b>a&amp;b</b> is invalid<br>See <a href="https://docs.pi-hole.net">docs</a>`
+
+		var out apiResponse
+		err := c.decodeAndCheck(respond(http.StatusOK, fmt.Sprintf(`{"success":false,"message":%q}`, raw)), &out)
+
+		var apiErr *APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, "Domain a&b is invalid See docs", apiErr.Message)
+		assert.Equal(t, raw, apiErr.RawMessage)
+	})
+
+	t.Run("error when FTL is not running", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		var out apiResponse
+		err := c.decodeAndCheck(respond(http.StatusOK, `{"FTLnotrunning":true}`), &out)
+
+		assert.ErrorIs(t, err, ErrFTLNotRunning)
+	})
+
+	t.Run("use custom success checker", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		errNotOK := errors.New("status is not ok")
+		custom := &Client{maxResponseBytes: DefaultMaxResponseBytes, successChecker: func(body []byte) error {
+			if !strings.Contains(string(body), `"status":"ok"`) {
+				return errNotOK
+			}
+			return nil
+		}}
+
+		var out apiResponse
+		assert.NoError(t, custom.decodeAndCheck(respond(http.StatusOK, `{"status":"ok"}`), &out))
+		assert.ErrorIs(t, custom.decodeAndCheck(respond(http.StatusOK, `{"status":"error"}`), &out), errNotOK)
+	})
+
+	t.Run("error on non-2xx status", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		var out apiResponse
+		err := c.decodeAndCheck(respond(http.StatusNotFound, `not found`), &out)
+
+		assert.ErrorIs(t, err, ErrUnexpectedStatus)
+	})
+}
+
+func TestClientConcurrentUse(t *testing.T) {
+	t.Run("share one client across goroutines", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{AuditFunc: func(AuditEvent) {}}, fake.ServeHTTP)
+		ctx := context.Background()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				domain := fmt.Sprintf("host%d.lan", i)
+
+				_, err := c.LocalDNS.Create(ctx, domain, "127.0.0.1")
+				assert.NoError(t, err)
+
+				_, err = c.LocalDNS.List(ctx)
+				assert.NoError(t, err)
+
+				assert.NoError(t, c.LocalDNS.Delete(ctx, domain))
+			}(i)
+		}
+		wg.Wait()
+
+		assert.Empty(t, fake.list())
+	})
+}
+
 func isAcceptance(t *testing.T) {
 	if os.Getenv("TEST_ACC") != "1" {
 		t.Skip("skipping acceptance test")
@@ -76,6 +419,22 @@ func newTestClient(t *testing.T) *Client {
 	return c
 }
 
+// newUnitTestClient returns a client pointed at a local test server serving handler
+func newUnitTestClient(t *testing.T, config Config, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	config.BaseURL = server.URL
+	if config.APIToken == "" && config.APITokenFile == "" {
+		config.APIToken = "token"
+	}
+
+	c, err := New(config)
+	require.NoError(t, err)
+
+	return c
+}
+
 func randomID() string {
 	b := make([]byte, 5)
 	if _, err := rand.Read(b); err != nil {
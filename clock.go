@@ -0,0 +1,41 @@
+package pihole
+
+import "time"
+
+// Clock tells the time for the client's time-dependent behavior: audit timestamps, the
+// record cache TTL, polling in AdBlocker.WaitUntilEnabled and query windows. Retry backoff
+// happens inside the HTTP transport and always uses real time.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock used when Config.Clock is unset
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// now returns the current time of the configured clock
+func (c Client) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+
+	return c.clock.Now()
+}
+
+// after waits for d on the configured clock
+func (c Client) after(d time.Duration) <-chan time.Time {
+	if c.clock == nil {
+		return time.After(d)
+	}
+
+	return c.clock.After(d)
+}
+
+// epochTime converts the epoch seconds Pi-hole reports into a UTC time. Epoch seconds do
+// not depend on the server's timezone, so no server location is needed.
+func epochTime(sec int64) time.Time {
+	return time.Unix(sec, 0).UTC()
+}
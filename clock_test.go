@@ -0,0 +1,119 @@
+package pihole
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a manually advanced Clock whose After fires at once, moving time forward
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- f.Advance(d)
+	return ch
+}
+
+// Advance moves the clock forward by d and returns the new time
+func (f *fakeClock) Advance(d time.Duration) time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+	return f.now
+}
+
+func TestClock(t *testing.T) {
+	start := time.Date(2022, 4, 15, 12, 0, 0, 0, time.UTC)
+
+	t.Run("convert epoch seconds to UTC", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		ts := epochTime(1650000000)
+		assert.Equal(t, time.UTC, ts.Location())
+		assert.Equal(t, time.Date(2022, 4, 15, 5, 20, 0, 0, time.UTC), ts)
+	})
+
+	t.Run("expire the cache on the configured clock", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		clock := &fakeClock{now: start}
+		fake := newFakeLocalDNS()
+
+		var gets int32
+		c := newUnitTestClient(t, Config{CacheTTL: time.Minute, Clock: clock}, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&gets, 1)
+			fake.ServeHTTP(w, r)
+		})
+		ctx := context.Background()
+
+		_, err := c.LocalDNS.List(ctx)
+		require.NoError(t, err)
+
+		clock.Advance(59 * time.Second)
+		_, err = c.LocalDNS.List(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&gets))
+
+		clock.Advance(time.Second)
+		_, err = c.LocalDNS.List(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&gets))
+	})
+
+	t.Run("poll on the configured clock", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		clock := &fakeClock{now: start}
+
+		var polls int32
+		c := newUnitTestClient(t, Config{Clock: clock}, func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&polls, 1) < 3 {
+				fmt.Fprint(w, `{"status":"disabled"}`)
+				return
+			}
+			fmt.Fprint(w, `{"status":"enabled"}`)
+		})
+
+		require.NoError(t, c.AdBlocker.WaitUntilEnabled(context.Background(), time.Hour))
+		assert.Equal(t, start.Add(2*time.Hour), clock.Now())
+	})
+
+	t.Run("timestamp audit events with the configured clock", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		var events []AuditEvent
+		c := newUnitTestClient(t, Config{
+			Clock:     &fakeClock{now: start},
+			AuditFunc: func(event AuditEvent) { events = append(events, event) },
+		}, newFakeLocalDNS().ServeHTTP)
+
+		_, err := c.LocalDNS.Create(context.Background(), "a.lan", "10.0.0.1")
+		require.NoError(t, err)
+
+		require.Len(t, events, 1)
+		assert.Equal(t, start, events[0].Time)
+	})
+}
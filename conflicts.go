@@ -0,0 +1,50 @@
+package pihole
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// RecordConflict is a domain that has both custom DNS records and a CNAME record, which
+// dnsmasq does not resolve predictably
+type RecordConflict struct {
+	Domain      string
+	IPs         []string
+	CNAMETarget string
+}
+
+// DetectConflicts returns the domains that have both a custom DNS record and a CNAME
+// record, sorted by domain. Pi-hole offers no way to order or prioritise the two, so the
+// conflicts can only be fixed by removing one of them.
+func (c *Client) DetectConflicts(ctx context.Context) ([]RecordConflict, error) {
+	records, err := c.LocalDNS.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch custom DNS records: %w", err)
+	}
+
+	cnames, err := c.LocalCNAME.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch CNAME records: %w", err)
+	}
+
+	ips := make(map[string][]string)
+	for _, record := range records {
+		domain := normalizeDomain(record.Domain)
+		ips[domain] = append(ips[domain], record.IP)
+	}
+
+	conflicts := []RecordConflict{}
+	for _, cname := range cnames {
+		domain := normalizeDomain(cname.Domain)
+		if len(ips[domain]) > 0 {
+			conflicts = append(conflicts, RecordConflict{Domain: domain, IPs: ips[domain], CNAMETarget: cname.Target})
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Domain < conflicts[j].Domain
+	})
+
+	return conflicts, nil
+}
@@ -0,0 +1,40 @@
+package pihole
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectConflicts(t *testing.T) {
+	t.Run("report domains with both a record and a CNAME", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(
+			DNSRecord{Domain: "web.lan", IP: "10.0.0.1"},
+			DNSRecord{Domain: "web.lan", IP: "fd00::1"},
+			DNSRecord{Domain: "app.lan", IP: "10.0.0.2"},
+			DNSRecord{Domain: "db.lan", IP: "10.0.0.3"},
+		)
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Has("customcname") {
+				fmt.Fprint(w, `{"data":[["web.lan","proxy.lan"],["App.lan","proxy.lan"],["www.lan","web.lan"]]}`)
+				return
+			}
+			fake.ServeHTTP(w, r)
+		})
+
+		conflicts, err := c.DetectConflicts(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, []RecordConflict{
+			{Domain: "app.lan", IPs: []string{"10.0.0.2"}, CNAMETarget: "proxy.lan"},
+			{Domain: "web.lan", IPs: []string{"10.0.0.1", "fd00::1"}, CNAMETarget: "proxy.lan"},
+		}, conflicts)
+	})
+}
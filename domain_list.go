@@ -0,0 +1,219 @@
+package pihole
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type DomainList interface {
+	// List returns the entries of a domain list.
+	List(ctx context.Context, kind ListKind) ([]ListDomain, error)
+
+	// Add a domain to a list.
+	Add(ctx context.Context, domain string, kind ListKind) (AddResult, error)
+
+	// AddMany adds domains to a list in a single request.
+	AddMany(ctx context.Context, domains []string, kind ListKind) (AddResult, error)
+
+	// Delete a domain from a list.
+	Delete(ctx context.Context, domain string, kind ListKind) error
+}
+
+// ListKind selects one of Pi-hole's exact or regex allow/deny lists
+type ListKind string
+
+const (
+	Whitelist      ListKind = "white"
+	Blacklist      ListKind = "black"
+	RegexWhitelist ListKind = "regex_white"
+	RegexBlacklist ListKind = "regex_black"
+)
+
+// listKindTypes maps the domainlist.type column to its list
+var listKindTypes = map[int]ListKind{
+	0: Whitelist,
+	1: Blacklist,
+	2: RegexWhitelist,
+	3: RegexBlacklist,
+}
+
+type domainList struct {
+	client *Client
+}
+
+// ListDomain is an entry of a domain list
+type ListDomain struct {
+	ID           int
+	Kind         ListKind
+	Domain       string
+	Enabled      bool
+	Comment      string
+	Groups       []int
+	DateAdded    time.Time
+	DateModified time.Time
+}
+
+type listDomainsResponse struct {
+	Data []listDomainResponse `json:"data"`
+}
+
+type listDomainResponse struct {
+	ID           int    `json:"id"`
+	Type         int    `json:"type"`
+	Domain       string `json:"domain"`
+	Enabled      int    `json:"enabled"`
+	Comment      string `json:"comment"`
+	Groups       []int  `json:"groups"`
+	DateAdded    int64  `json:"date_added"`
+	DateModified int64  `json:"date_modified"`
+}
+
+func (res listDomainResponse) toListDomain() ListDomain {
+	return ListDomain{
+		ID:           res.ID,
+		Kind:         listKindTypes[res.Type],
+		Domain:       res.Domain,
+		Enabled:      res.Enabled != 0,
+		Comment:      res.Comment,
+		Groups:       res.Groups,
+		DateAdded:    epochTime(res.DateAdded),
+		DateModified: epochTime(res.DateModified),
+	}
+}
+
+// List returns the entries of the given list
+func (dl domainList) List(ctx context.Context, kind ListKind) ([]ListDomain, error) {
+	req, err := dl.client.Request(ctx, url.Values{
+		"list": []string{string(kind)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := dl.client.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer drainAndClose(res.Body)
+
+	var listRes *listDomainsResponse
+	if err := dl.client.decode(res.Body, &listRes); err != nil {
+		return nil, decodeError(fmt.Sprintf("%s list", kind), err)
+	}
+	if listRes == nil {
+		return nil, fmt.Errorf("failed to parse %s list body: missing data", kind)
+	}
+
+	list := make([]ListDomain, len(listRes.Data))
+	for i, domain := range listRes.Data {
+		list[i] = domain.toListDomain()
+	}
+
+	return list, nil
+}
+
+// Add adds a single domain to the given list
+func (dl domainList) Add(ctx context.Context, domain string, kind ListKind) (AddResult, error) {
+	return dl.AddMany(ctx, []string{domain}, kind)
+}
+
+// AddMany adds domains to the given list with a single request, using the space separated
+// batch format Pi-hole accepts. Domains already on the list are skipped by the server and
+// counted in the result. No request is made for an empty domains.
+func (dl domainList) AddMany(ctx context.Context, domains []string, kind ListKind) (AddResult, error) {
+	if len(domains) == 0 {
+		return AddResult{}, nil
+	}
+
+	for _, domain := range domains {
+		if domain == "" || strings.ContainsAny(domain, " \t\r\n") {
+			return AddResult{}, fmt.Errorf("%w: %q", ErrInvalidDomain, domain)
+		}
+	}
+
+	listRes, err := dl.modify(ctx, "add", strings.Join(domains, " "), kind)
+	if err != nil {
+		return AddResult{}, err
+	}
+
+	return parseAddResult(sanitizeMessage(listRes.Message), len(domains)), nil
+}
+
+// Delete removes a domain from the given list
+func (dl domainList) Delete(ctx context.Context, domain string, kind ListKind) error {
+	_, err := dl.modify(ctx, "sub", domain, kind)
+	return err
+}
+
+// modify sends an add or sub action for the given list, auditing one event per domain
+func (dl domainList) modify(ctx context.Context, action string, value string, kind ListKind) (listRes apiResponse, err error) {
+	defer func() {
+		auditAction := AuditAddListDomain
+		if action == "sub" {
+			auditAction = AuditDeleteListDomain
+		}
+		for _, domain := range strings.Fields(value) {
+			dl.client.audit(ctx, AuditEvent{Action: auditAction, Domain: domain, List: kind, Err: err})
+		}
+	}()
+
+	req, err := dl.client.Request(ctx, url.Values{
+		"list": []string{string(kind)},
+		action: []string{value},
+	})
+	if err != nil {
+		return listRes, err
+	}
+
+	res, err := dl.client.http.Do(req)
+	if err != nil {
+		return listRes, err
+	}
+
+	defer drainAndClose(res.Body)
+
+	if err := dl.client.decodeAndCheck(res, &listRes); err != nil {
+		return listRes, fmt.Errorf("failed to %s %s on %s list: %w", action, value, kind, err)
+	}
+
+	return listRes, nil
+}
+
+// AddResult counts the outcome of adding domains to a list
+type AddResult struct {
+	Added          int
+	AlreadyPresent int
+}
+
+var (
+	addedCountMessage = regexp.MustCompile(`^Added (\d+) (?:out of \d+ )?domains`)
+)
+
+// parseAddResult reads the counts from the success message of an add action for total
+// domains. Pi-hole words the message differently for single and batch adds:
+//
+//	Added example.com
+//	Not adding example.com as it is already on the list
+//	Added 3 domains
+//	Added 2 out of 3 domains (skipped duplicates)
+//
+// A message in none of these forms is counted as every domain added.
+func parseAddResult(message string, total int) AddResult {
+	added := total
+
+	if m := addedCountMessage.FindStringSubmatch(message); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil && n <= total {
+			added = n
+		}
+	} else if strings.HasPrefix(message, "Not adding ") {
+		added = 0
+	}
+
+	return AddResult{Added: added, AlreadyPresent: total - added}
+}
@@ -0,0 +1,148 @@
+package pihole
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainList(t *testing.T) {
+	t.Run("parse list entries", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "black", r.URL.Query().Get("list"))
+			fmt.Fprint(w, `{"data":[{"id":3,"type":1,"domain":"ads.example.com","enabled":1,"date_added":1650000000,"date_modified":1650000001,"comment":"noisy","groups":[0,2]}]}`)
+		})
+
+		list, err := c.DomainList.List(context.Background(), Blacklist)
+		require.NoError(t, err)
+
+		assert.Equal(t, []ListDomain{{
+			ID:           3,
+			Kind:         Blacklist,
+			Domain:       "ads.example.com",
+			Enabled:      true,
+			Comment:      "noisy",
+			Groups:       []int{0, 2},
+			DateAdded:    time.Unix(1650000000, 0).UTC(),
+			DateModified: time.Unix(1650000001, 0).UTC(),
+		}}, list)
+	})
+
+	t.Run("error on null body", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `null`)
+		})
+
+		_, err := c.DomainList.List(context.Background(), Blacklist)
+		assert.Error(t, err)
+	})
+
+	t.Run("add many domains in one request", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		var requests int32
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			assert.Equal(t, "white", r.URL.Query().Get("list"))
+			assert.Equal(t, "a.example.com b.example.com", r.URL.Query().Get("add"))
+			fmt.Fprint(w, `{"success":true,"message":"Added 2 domains"}`)
+		})
+
+		result, err := c.DomainList.AddMany(context.Background(), []string{"a.example.com", "b.example.com"}, Whitelist)
+		require.NoError(t, err)
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+		assert.Equal(t, AddResult{Added: 2}, result)
+	})
+
+	t.Run("add nothing without a request for no domains", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			t.Errorf("unexpected request: %s", r.URL)
+		})
+
+		result, err := c.DomainList.AddMany(context.Background(), nil, Blacklist)
+		require.NoError(t, err)
+		assert.Equal(t, AddResult{}, result)
+	})
+
+	t.Run("count domains skipped as duplicates", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"success":true,"message":"Added 1 out of 3 domains (skipped duplicates)"}`)
+		})
+
+		result, err := c.DomainList.AddMany(context.Background(), []string{"a.example.com", "b.example.com", "c.example.com"}, Blacklist)
+		require.NoError(t, err)
+
+		assert.Equal(t, AddResult{Added: 1, AlreadyPresent: 2}, result)
+	})
+
+	t.Run("count a single domain already on the list", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"success":true,"message":"Not adding a.example.com as it is already on the list"}`)
+		})
+
+		result, err := c.DomainList.Add(context.Background(), "a.example.com", Blacklist)
+		require.NoError(t, err)
+
+		assert.Equal(t, AddResult{AlreadyPresent: 1}, result)
+	})
+
+	t.Run("error on domain containing a separator", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			t.Error("unexpected request")
+		})
+
+		_, err := c.DomainList.AddMany(context.Background(), []string{"a.example.com b.example.com"}, Blacklist)
+		assert.ErrorIs(t, err, ErrInvalidDomain)
+	})
+
+	t.Run("add and delete a domain", func(t *testing.T) {
+		isAcceptance(t)
+
+		c := newTestClient(t)
+		ctx := context.Background()
+
+		domain := fmt.Sprintf("test.%s.com", randomID())
+
+		result, err := c.DomainList.Add(ctx, domain, Blacklist)
+		require.NoError(t, err)
+		assert.Equal(t, AddResult{Added: 1}, result)
+
+		list, err := c.DomainList.List(ctx, Blacklist)
+		require.NoError(t, err)
+
+		found := false
+		for _, entry := range list {
+			found = found || entry.Domain == domain
+		}
+		assert.True(t, found)
+
+		err = c.DomainList.Delete(ctx, domain, Blacklist)
+		require.NoError(t, err)
+	})
+}
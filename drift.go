@@ -0,0 +1,126 @@
+package pihole
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+)
+
+// DriftReport lists how the custom DNS records of two Pi-holes differ
+type DriftReport struct {
+	// OnlyInA holds the records of domains that b has no record for
+	OnlyInA DNSRecordList
+
+	// OnlyInB holds the records of domains that a has no record for
+	OnlyInB DNSRecordList
+
+	// Differing holds the domains both have records for, but with different IPs
+	Differing []DomainDrift
+}
+
+// DomainDrift is a domain whose IPs differ between two Pi-holes
+type DomainDrift struct {
+	Domain string
+	AIPs   []string
+	BIPs   []string
+}
+
+// InSync reports whether the report found no differences
+func (r DriftReport) InSync() bool {
+	return len(r.OnlyInA) == 0 && len(r.OnlyInB) == 0 && len(r.Differing) == 0
+}
+
+// CompareLocalDNS lists the custom DNS records of a and b and reports where they differ,
+// without changing either. Domains are compared case-insensitively and IPs by address, so
+// 10.0.0.1 and ::ffff:10.0.0.1 match; duplicate records are ignored. All results are sorted
+// by domain.
+func CompareLocalDNS(ctx context.Context, a, b LocalDNS) (DriftReport, error) {
+	listA, err := a.List(ctx)
+	if err != nil {
+		return DriftReport{}, fmt.Errorf("failed to fetch custom DNS records of a: %w", err)
+	}
+
+	listB, err := b.List(ctx)
+	if err != nil {
+		return DriftReport{}, fmt.Errorf("failed to fetch custom DNS records of b: %w", err)
+	}
+
+	ipsA, ipsB := groupIPs(listA), groupIPs(listB)
+
+	var report DriftReport
+	for _, domain := range sortedKeys(ipsA) {
+		other, ok := ipsB[domain]
+		switch {
+		case !ok:
+			for _, ip := range ipsA[domain] {
+				report.OnlyInA = append(report.OnlyInA, DNSRecord{Domain: domain, IP: ip})
+			}
+		case !equalStrings(ipsA[domain], other):
+			report.Differing = append(report.Differing, DomainDrift{Domain: domain, AIPs: ipsA[domain], BIPs: other})
+		}
+	}
+
+	for _, domain := range sortedKeys(ipsB) {
+		if _, ok := ipsA[domain]; !ok {
+			for _, ip := range ipsB[domain] {
+				report.OnlyInB = append(report.OnlyInB, DNSRecord{Domain: domain, IP: ip})
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// groupIPs maps each normalized domain of list to its sorted, deduplicated canonical IPs
+func groupIPs(list DNSRecordList) map[string][]string {
+	seen := make(map[DNSRecord]bool, len(list))
+	ips := make(map[string][]string)
+
+	for _, record := range list {
+		record = DNSRecord{Domain: normalizeDomain(record.Domain), IP: canonicalIP(record.IP)}
+		if !seen[record] {
+			ips[record.Domain] = append(ips[record.Domain], record.IP)
+		}
+		seen[record] = true
+	}
+
+	for _, list := range ips {
+		sort.Strings(list)
+	}
+
+	return ips
+}
+
+// canonicalIP returns the unmapped textual form of ip, or ip itself when it does not parse
+func canonicalIP(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+
+	return addr.Unmap().String()
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,64 @@
+package pihole
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareLocalDNS(t *testing.T) {
+	t.Run("report records only on one side and differing IPs", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fakeA := newFakeLocalDNS(
+			DNSRecord{Domain: "same.lan", IP: "10.0.0.1"},
+			DNSRecord{Domain: "moved.lan", IP: "10.0.0.2"},
+			DNSRecord{Domain: "a.lan", IP: "10.0.0.3"},
+		)
+		fakeB := newFakeLocalDNS(
+			DNSRecord{Domain: "Same.lan", IP: "::ffff:10.0.0.1"},
+			DNSRecord{Domain: "moved.lan", IP: "10.0.0.20"},
+			DNSRecord{Domain: "b.lan", IP: "10.0.0.4"},
+		)
+		a := newUnitTestClient(t, Config{}, fakeA.ServeHTTP)
+		b := newUnitTestClient(t, Config{}, fakeB.ServeHTTP)
+
+		report, err := CompareLocalDNS(context.Background(), a.LocalDNS, b.LocalDNS)
+		require.NoError(t, err)
+
+		assert.Equal(t, DriftReport{
+			OnlyInA:   DNSRecordList{{Domain: "a.lan", IP: "10.0.0.3"}},
+			OnlyInB:   DNSRecordList{{Domain: "b.lan", IP: "10.0.0.4"}},
+			Differing: []DomainDrift{{Domain: "moved.lan", AIPs: []string{"10.0.0.2"}, BIPs: []string{"10.0.0.20"}}},
+		}, report)
+		assert.False(t, report.InSync())
+
+		assert.Len(t, fakeA.list(), 3)
+		assert.Len(t, fakeB.list(), 3)
+	})
+
+	t.Run("report in sync regardless of order and duplicates", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fakeA := newFakeLocalDNS(
+			DNSRecord{Domain: "multi.lan", IP: "10.0.0.1"},
+			DNSRecord{Domain: "multi.lan", IP: "10.0.0.2"},
+		)
+		fakeB := newFakeLocalDNS(
+			DNSRecord{Domain: "multi.lan", IP: "10.0.0.2"},
+			DNSRecord{Domain: "multi.lan", IP: "10.0.0.1"},
+			DNSRecord{Domain: "multi.lan", IP: "10.0.0.1"},
+		)
+		a := newUnitTestClient(t, Config{}, fakeA.ServeHTTP)
+		b := newUnitTestClient(t, Config{}, fakeB.ServeHTTP)
+
+		report, err := CompareLocalDNS(context.Background(), a.LocalDNS, b.LocalDNS)
+		require.NoError(t, err)
+
+		assert.True(t, report.InSync())
+	})
+}
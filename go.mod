@@ -1,15 +1,18 @@
 module github.com/mhaii/go-pihole
 
-go 1.17
+go 1.20
 
 require (
 	github.com/hashicorp/go-retryablehttp v0.7.0
 	github.com/stretchr/testify v1.7.0
+	golang.org/x/net v0.23.0
+	golang.org/x/sync v0.7.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/hashicorp/go-cleanhttp v0.5.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
 )
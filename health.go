@@ -0,0 +1,61 @@
+package pihole
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Health is a snapshot of the connected Pi-hole's state
+type Health struct {
+	// Reachable is set when the server answered either probe
+	Reachable bool
+
+	// Authenticated is set when the server accepted the API token
+	Authenticated bool
+
+	FTLRunning      bool
+	BlockingEnabled bool
+
+	// Version is the Pi-hole core version, empty when unreachable
+	Version string
+}
+
+// Health probes the server's version and its summary and combines them into one snapshot.
+// Each probe runs whatever the other returned, and a failing probe leaves only its own
+// fields unset: the snapshot is always returned, along with the probe errors joined. FTL
+// not running is reported in the snapshot, not as an error.
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	health := &Health{}
+	var errs []error
+
+	versions, err := c.Version.Get(ctx)
+	switch {
+	case err != nil:
+		errs = append(errs, fmt.Errorf("version probe: %w", err))
+	case versions == nil:
+		errs = append(errs, errors.New("version probe: missing versions"))
+	default:
+		health.Reachable = true
+		health.Version = versions.CoreCurrent
+	}
+
+	summary, err := c.Stats.Summary(ctx)
+	switch {
+	case err == nil:
+		health.Reachable = true
+		health.Authenticated = true
+		health.FTLRunning = true
+		health.BlockingEnabled = summary.Status == "enabled"
+	case errors.Is(err, ErrFTLNotRunning):
+		health.Reachable = true
+		health.Authenticated = true
+	case errors.Is(err, ErrNotSupported):
+		health.Reachable = true
+		errs = append(errs, fmt.Errorf("summary probe: API token not accepted: %w", err))
+	default:
+		errs = append(errs, fmt.Errorf("summary probe: %w", err))
+	}
+
+	return health, errors.Join(errs...)
+}
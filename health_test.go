@@ -0,0 +1,129 @@
+package pihole
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealth(t *testing.T) {
+	newHealthClient := func(t *testing.T, summary string) *Client {
+		return newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Has("versions") {
+				fmt.Fprint(w, `{"core_current":"v5.18","web_current":"v5.21","FTL_current":"v5.24"}`)
+				return
+			}
+			fmt.Fprint(w, summary)
+		})
+	}
+
+	t.Run("report a healthy server", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newHealthClient(t, `{"status":"enabled","gravity_last_updated":{"file_exists":true,"absolute":1650000000}}`)
+
+		health, err := c.Health(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, &Health{Reachable: true, Authenticated: true, FTLRunning: true, BlockingEnabled: true, Version: "v5.18"}, health)
+	})
+
+	t.Run("report FTL not running without an error", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newHealthClient(t, `{"FTLnotrunning":true}`)
+
+		health, err := c.Health(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, &Health{Reachable: true, Authenticated: true, Version: "v5.18"}, health)
+	})
+
+	t.Run("keep the version when the token is refused", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newHealthClient(t, `[]`)
+
+		health, err := c.Health(context.Background())
+		assert.ErrorIs(t, err, ErrNotSupported)
+
+		assert.Equal(t, &Health{Reachable: true, Version: "v5.18"}, health)
+	})
+
+	t.Run("run the summary probe when the version probe fails", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{HttpClient: &http.Client{}}, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Has("versions") {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			fmt.Fprint(w, `{"status":"enabled"}`)
+		})
+
+		health, err := c.Health(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "version probe")
+
+		assert.Equal(t, &Health{Reachable: true, Authenticated: true, FTLRunning: true, BlockingEnabled: true}, health)
+	})
+
+	t.Run("fail the version probe on a null versions body", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Has("versions") {
+				fmt.Fprint(w, `null`)
+				return
+			}
+			fmt.Fprint(w, `{"status":"enabled"}`)
+		})
+
+		health, err := c.Health(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "version probe")
+
+		assert.Equal(t, &Health{Reachable: true, Authenticated: true, FTLRunning: true, BlockingEnabled: true}, health)
+	})
+
+	t.Run("fail the version probe on a nil result", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newHealthClient(t, `{"status":"enabled"}`)
+		c.Version = nilVersion{}
+
+		health, err := c.Health(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "version probe")
+
+		assert.Equal(t, &Health{Reachable: true, Authenticated: true, FTLRunning: true, BlockingEnabled: true}, health)
+	})
+
+	t.Run("report an unreachable server", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{HttpClient: &http.Client{}}, func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		})
+
+		health, err := c.Health(context.Background())
+		assert.Error(t, err)
+		assert.Equal(t, &Health{}, health)
+	})
+}
+
+// nilVersion is a Version returning neither versions nor an error
+type nilVersion struct{}
+
+func (nilVersion) Get(ctx context.Context) (*ComponentVersions, error) { return nil, nil }
@@ -0,0 +1,177 @@
+package pihole
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseHosts reads records from a hosts file, one "IP name [alias...]" entry per line.
+// Every name and alias becomes a record with a canonical domain; comments and blank lines
+// are skipped.
+func ParseHosts(r io.Reader) (DNSRecordList, error) {
+	list, _, err := parseHosts(r, false)
+	return list, err
+}
+
+// parseHosts implements ParseHosts. With skipInvalid, malformed lines and names are left
+// out and returned as errors instead of failing the parse.
+func parseHosts(r io.Reader, skipInvalid bool) (list DNSRecordList, invalid []error, err error) {
+	reject := func(err error) error {
+		if !skipInvalid {
+			return err
+		}
+		invalid = append(invalid, err)
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			if err := reject(fmt.Errorf("hosts line %d: expected an IP followed by at least one name: %q", line, scanner.Text())); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		if err := validateIP(fields[0]); err != nil {
+			if err := reject(fmt.Errorf("hosts line %d: %w", line, err)); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		for _, name := range fields[1:] {
+			record := DNSRecord{Domain: normalizeDomain(name), IP: fields[0]}
+			if err := validateDomain(record.Domain); err != nil {
+				if err := reject(fmt.Errorf("hosts line %d: %w", line, err)); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			list = append(list, record)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read hosts file: %w", err)
+	}
+
+	return list, invalid, nil
+}
+
+// ImportOptions relaxes ImportHosts for input that is not perfectly clean. Domains are
+// always lowercased and stripped of a trailing dot, so there are no options for that.
+type ImportOptions struct {
+	// SkipInvalid leaves out malformed lines and names, reporting them in
+	// ImportResult.Invalid, instead of failing before anything is created
+	SkipInvalid bool
+
+	// SkipDuplicates leaves out records already on the server or earlier in the input,
+	// instead of failing when the server refuses to add them again
+	SkipDuplicates bool
+}
+
+// ImportResult holds the outcome of ImportHosts
+type ImportResult struct {
+	// Created holds the records created, even when the import stopped early
+	Created DNSRecordList
+
+	// Duplicates holds the records skipped by SkipDuplicates
+	Duplicates DNSRecordList
+
+	// Invalid holds one error per line or name skipped by SkipInvalid
+	Invalid []error
+}
+
+// ImportHosts parses a hosts file and creates its records with CreateMany, skipping bad
+// input and existing records as opts allow. Nothing is deleted.
+func (dns localDNS) ImportHosts(ctx context.Context, r io.Reader, opts ImportOptions) (ImportResult, error) {
+	var result ImportResult
+
+	records, invalid, err := parseHosts(r, opts.SkipInvalid)
+	result.Invalid = invalid
+	if err != nil {
+		return result, err
+	}
+
+	if opts.SkipDuplicates {
+		current, err := dns.List(ctx)
+		if err != nil {
+			return result, fmt.Errorf("failed to fetch custom DNS records: %w", err)
+		}
+
+		seen := make(map[DNSRecord]bool, len(current)+len(records))
+		for _, record := range current {
+			seen[canonicalRecord(record)] = true
+		}
+
+		unique := DNSRecordList{}
+		for _, record := range records {
+			key := DNSRecord{Domain: record.Domain, IP: canonicalIP(record.IP)}
+			if seen[key] {
+				result.Duplicates = append(result.Duplicates, record)
+				continue
+			}
+			seen[key] = true
+			unique = append(unique, record)
+		}
+		records = unique
+	}
+
+	batch, err := dns.CreateMany(ctx, records)
+	result.Created = batch.Records
+
+	return result, err
+}
+
+// PlanFromHosts parses a hosts file and returns the records that would have to be added
+// and removed for the custom DNS records to match it. Nothing is changed on the server.
+func (dns localDNS) PlanFromHosts(ctx context.Context, r io.Reader) (toAdd, toRemove DNSRecordList, err error) {
+	desired, err := ParseHosts(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current, err := dns.List(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch custom DNS records: %w", err)
+	}
+
+	// Compare canonical forms so records stored as MyHost.lan, host.lan. or fd00:0::1 are not
+	// reported as drift, but return removals as stored so they can be deleted
+	canonicalCurrent := make(DNSRecordList, len(current))
+	for i, record := range current {
+		canonicalCurrent[i] = canonicalRecord(record)
+	}
+
+	canonicalDesired := make(DNSRecordList, len(desired))
+	inDesired := make(map[DNSRecord]bool, len(desired))
+	for i, record := range desired {
+		canonicalDesired[i] = canonicalRecord(record)
+		inDesired[canonicalDesired[i]] = true
+	}
+
+	toAdd, _ = DiffRecords(canonicalCurrent, canonicalDesired)
+
+	for _, record := range current {
+		if !inDesired[canonicalRecord(record)] {
+			toRemove = append(toRemove, record)
+		}
+	}
+
+	return toAdd, toRemove, nil
+}
+
+// canonicalRecord returns record with its domain and IP in canonical form, for comparison
+func canonicalRecord(record DNSRecord) DNSRecord {
+	return DNSRecord{Domain: normalizeDomain(record.Domain), IP: canonicalIP(record.IP)}
+}
@@ -0,0 +1,125 @@
+package pihole
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHosts(t *testing.T) {
+	t.Run("parse names and aliases", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		list, err := ParseHosts(strings.NewReader(`
+# local hosts
+10.0.0.1   NAS.lan nas-alias.lan # storage
+fe80::1    router.lan
+`))
+		require.NoError(t, err)
+
+		assert.Equal(t, DNSRecordList{
+			{Domain: "nas.lan", IP: "10.0.0.1"},
+			{Domain: "nas-alias.lan", IP: "10.0.0.1"},
+			{Domain: "router.lan", IP: "fe80::1"},
+		}, list)
+	})
+
+	t.Run("error on malformed line", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		_, err := ParseHosts(strings.NewReader("10.0.0.1 ok.lan\nnot-an-ip bad.lan\n"))
+
+		assert.ErrorIs(t, err, ErrInvalidIP)
+		assert.Contains(t, err.Error(), "line 2")
+	})
+}
+
+func TestLocalDNSPlanFromHosts(t *testing.T) {
+	t.Run("plan changes without applying them", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		current := []DNSRecord{
+			{Domain: "keep.lan", IP: "10.0.0.1"},
+			{Domain: "stale.lan", IP: "10.0.0.2"},
+		}
+		fake := newFakeLocalDNS(current...)
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		toAdd, toRemove, err := c.LocalDNS.PlanFromHosts(context.Background(), strings.NewReader("10.0.0.1 keep.lan\n10.0.0.3 new.lan\n"))
+		require.NoError(t, err)
+
+		assert.Equal(t, DNSRecordList{{Domain: "new.lan", IP: "10.0.0.3"}}, toAdd)
+		assert.Equal(t, DNSRecordList{{Domain: "stale.lan", IP: "10.0.0.2"}}, toRemove)
+		assert.Equal(t, DNSRecordList(current), fake.list())
+	})
+
+	t.Run("ignore records differing only in spelling", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(
+			DNSRecord{Domain: "MyHost.lan", IP: "10.0.0.1"},
+			DNSRecord{Domain: "host.lan.", IP: "10.0.0.2"},
+			DNSRecord{Domain: "v6.lan", IP: "fd00:0::1"},
+			DNSRecord{Domain: "Stale.lan.", IP: "10.0.0.9"},
+		)
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		toAdd, toRemove, err := c.LocalDNS.PlanFromHosts(context.Background(), strings.NewReader("10.0.0.1 myhost.lan\n10.0.0.2 host.lan\nfd00::1 v6.lan\n"))
+		require.NoError(t, err)
+
+		assert.Empty(t, toAdd)
+		assert.Equal(t, DNSRecordList{{Domain: "Stale.lan.", IP: "10.0.0.9"}}, toRemove)
+	})
+}
+
+func TestLocalDNSImportHosts(t *testing.T) {
+	hosts := "10.0.0.1 existing.lan\nnot-an-ip bad.lan\n10.0.0.2 New.lan. new.lan bad_name!.lan\n"
+
+	t.Run("skip invalid input and duplicates", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(DNSRecord{Domain: "existing.lan", IP: "10.0.0.1"})
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		result, err := c.LocalDNS.ImportHosts(context.Background(), strings.NewReader(hosts), ImportOptions{
+			SkipInvalid:    true,
+			SkipDuplicates: true,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, DNSRecordList{{Domain: "new.lan", IP: "10.0.0.2"}}, result.Created)
+		assert.Equal(t, DNSRecordList{
+			{Domain: "existing.lan", IP: "10.0.0.1"},
+			{Domain: "new.lan", IP: "10.0.0.2"},
+		}, result.Duplicates)
+		require.Len(t, result.Invalid, 2)
+		assert.ErrorIs(t, result.Invalid[0], ErrInvalidIP)
+		assert.ErrorIs(t, result.Invalid[1], ErrInvalidDomain)
+		assert.Equal(t, DNSRecordList{
+			{Domain: "existing.lan", IP: "10.0.0.1"},
+			{Domain: "new.lan", IP: "10.0.0.2"},
+		}, fake.list())
+	})
+
+	t.Run("fail on invalid input without creating any", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		result, err := c.LocalDNS.ImportHosts(context.Background(), strings.NewReader(hosts), ImportOptions{})
+
+		assert.ErrorIs(t, err, ErrInvalidIP)
+		assert.Empty(t, result.Created)
+		assert.Empty(t, fake.list())
+	})
+}
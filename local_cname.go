@@ -2,7 +2,6 @@ package pihole
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
@@ -24,7 +23,7 @@ type LocalCNAME interface {
 }
 
 var (
-	ErrorLocalCNAMENotFound = errors.New("local CNAME record not found")
+	ErrorLocalCNAMENotFound = fmt.Errorf("local CNAME record %w", ErrNotFound)
 )
 
 type localCNAME struct {
@@ -36,12 +35,6 @@ type CNAMERecord struct {
 	Target string
 }
 
-type cnameRecordResponse struct {
-	Success       bool   `json:"success"`
-	Message       string `json:"message"`
-	FTLNotRunning bool   `json:"FTLnotrunning"`
-}
-
 type cnameRecordListResponse struct {
 	Data []cnameRecordResponseObject `json:"data"`
 }
@@ -82,11 +75,11 @@ func (cname localCNAME) List(ctx context.Context) (CNAMERecordList, error) {
 		return nil, err
 	}
 
-	defer res.Body.Close()
+	defer drainAndClose(res.Body)
 
 	var resList *cnameRecordListResponse
-	if err := json.NewDecoder(res.Body).Decode(&resList); err != nil {
-		return nil, fmt.Errorf("failed to parse custom CNAME list body: %w", err)
+	if err := cname.client.decode(res.Body, &resList); err != nil {
+		return nil, decodeError("custom CNAME list", err)
 	}
 
 	return resList.toCNAMERecordList(), nil
@@ -94,6 +87,19 @@ func (cname localCNAME) List(ctx context.Context) (CNAMERecordList, error) {
 
 // Create creates a CNAME record
 func (cname localCNAME) Create(ctx context.Context, domain string, target string) (*CNAMERecord, error) {
+	if err := cname.add(ctx, domain, target); err != nil {
+		return nil, err
+	}
+
+	return cname.Get(ctx, domain)
+}
+
+// add sends a single CNAME record to the server
+func (cname localCNAME) add(ctx context.Context, domain string, target string) (err error) {
+	defer func() {
+		cname.client.audit(ctx, AuditEvent{Action: AuditCreateCNAME, Domain: domain, Target: target, Err: err})
+	}()
+
 	req, err := cname.client.Request(ctx, url.Values{
 		"customcname": []string{"true"},
 		"action":      []string{"add"},
@@ -101,26 +107,22 @@ func (cname localCNAME) Create(ctx context.Context, domain string, target string
 		"target":      []string{target},
 	})
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	res, err := cname.client.http.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	defer res.Body.Close()
-
-	var dnsRes *cnameRecordResponse
-	if err := json.NewDecoder(res.Body).Decode(&dnsRes); err != nil {
-		return nil, fmt.Errorf("failed to parse custom CNAME response body: %w", err)
-	}
+	defer drainAndClose(res.Body)
 
-	if !dnsRes.Success {
-		return nil, fmt.Errorf("failed to create CNAME record %s %s : %s : %w", domain, target, dnsRes.Message, err)
+	var cnameRes apiResponse
+	if err := cname.client.decodeAndCheck(res, &cnameRes); err != nil {
+		return fmt.Errorf("failed to create CNAME record %s %s: %w", domain, target, err)
 	}
 
-	return cname.Get(ctx, domain)
+	return nil
 }
 
 // Get returns a CNAME record by the passed domain
@@ -149,6 +151,15 @@ func (cname localCNAME) Delete(ctx context.Context, domain string) error {
 		return fmt.Errorf("failed looking up CNAME record %s for deletion: %w", domain, err)
 	}
 
+	return cname.deleteRecord(ctx, *record)
+}
+
+// deleteRecord removes a single CNAME record matching both its domain and target
+func (cname localCNAME) deleteRecord(ctx context.Context, record CNAMERecord) (err error) {
+	defer func() {
+		cname.client.audit(ctx, AuditEvent{Action: AuditDeleteCNAME, Domain: record.Domain, Target: record.Target, Err: err})
+	}()
+
 	req, err := cname.client.Request(ctx, url.Values{
 		"customcname": []string{"true"},
 		"action":      []string{"delete"},
@@ -164,15 +175,11 @@ func (cname localCNAME) Delete(ctx context.Context, domain string) error {
 		return err
 	}
 
-	defer res.Body.Close()
-
-	var delRes cnameRecordResponse
-	if err := json.NewDecoder(res.Body).Decode(&delRes); err != nil {
-		return fmt.Errorf("failed to parse CNAME deletion response body: %w", err)
-	}
+	defer drainAndClose(res.Body)
 
-	if !delRes.Success {
-		return fmt.Errorf("failed to delete CNAME record %s: %s", domain, delRes.Message)
+	var delRes apiResponse
+	if err := cname.client.decodeAndCheck(res, &delRes); err != nil {
+		return fmt.Errorf("failed to delete CNAME record %s: %w", record.Domain, err)
 	}
 
 	return nil
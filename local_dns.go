@@ -5,29 +5,126 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/netip"
 	"net/url"
+	"regexp"
+	"sort"
 	"strings"
+
+	"golang.org/x/net/idna"
 )
 
 type LocalDNS interface {
-	// List all DNS records.
+	// List all DNS records as the server holds them, in the order of the API response,
+	// which can include the same domain and IP more than once.
 	List(ctx context.Context) (DNSRecordList, error)
 
-	// Create a DNS record.
+	// ListStable lists all DNS records, guaranteed in the order of the API response.
+	ListStable(ctx context.Context) (DNSRecordList, error)
+
+	// ListUnique lists all DNS records with identical duplicates removed.
+	ListUnique(ctx context.Context) (DNSRecordList, error)
+
+	// ListRaw returns the raw list response body alongside the parsed records.
+	ListRaw(ctx context.Context) (json.RawMessage, DNSRecordList, error)
+
+	// ListFunc lists the DNS records for which keep returns true.
+	ListFunc(ctx context.Context, keep func(DNSRecord) bool) (DNSRecordList, error)
+
+	// ListByFamily splits the DNS records into IPv4 and IPv6 records.
+	ListByFamily(ctx context.Context) (v4, v6 DNSRecordList, err error)
+
+	// Domains returns the sorted, deduplicated domains of all DNS records.
+	Domains(ctx context.Context) ([]string, error)
+
+	// ExistsMany reports which of the given domains have a DNS record.
+	ExistsMany(ctx context.Context, domains []string) (map[string]bool, error)
+
+	// Create a DNS record. If the record was added but reading it back failed because ctx
+	// expired, the record is returned together with an error wrapping ErrLocalDNSUnverified.
+	// An invalid domain or IP fails with ErrInvalidDomain or ErrInvalidIP without a request.
 	Create(ctx context.Context, domain string, IP string) (*DNSRecord, error)
 
+	// CreateFromDevice creates a record naming a network device under domainSuffix.
+	CreateFromDevice(ctx context.Context, dev NetworkDevice, domainSuffix string) (*DNSRecord, error)
+
 	// Get first DNS record by its domain.
 	Get(ctx context.Context, domain string) (*DNSRecord, error)
 
-	// GetList of all DNS records by its domain
+	// GetList of all DNS records by its domain, or by IP when given an address.
 	GetList(ctx context.Context, domain string) ([]*DNSRecord, error)
 
+	// GetByCIDR returns all DNS records whose IP is within cidr.
+	GetByCIDR(ctx context.Context, cidr string) (DNSRecordList, error)
+
+	// GetByRegexp returns all DNS records whose lowercased domain matches pattern.
+	GetByRegexp(ctx context.Context, pattern string) (DNSRecordList, error)
+
 	// Delete a DNS record by its domain.
 	Delete(ctx context.Context, domain string) error
+
+	// SwapIP points domain at toIP instead of fromIP without a moment where it has neither.
+	SwapIP(ctx context.Context, domain string, fromIP string, toIP string) error
+
+	// CreateMany creates DNS records in order, returning the ones created before any failure.
+	CreateMany(ctx context.Context, records DNSRecordList) (*BatchResult, error)
+
+	// CreateManyWithProgress is CreateMany sending a Progress after each record without blocking.
+	CreateManyWithProgress(ctx context.Context, records DNSRecordList, progress chan<- Progress) (*BatchResult, error)
+
+	// Upsert creates missing records and updates the IP of records whose domain exists.
+	Upsert(ctx context.Context, records DNSRecordList) (created, updated DNSRecordList, err error)
+
+	// DeleteMany deletes all DNS records of the given domains, returning the ones deleted.
+	DeleteMany(ctx context.Context, domains []string, opts DeleteManyOptions) (*BatchResult, error)
+
+	// DeleteByIP deletes all DNS records pointing at ip, returning how many were deleted.
+	DeleteByIP(ctx context.Context, ip string) (int, error)
+
+	// Reconcile adds and optionally deletes records so the server matches desired.
+	Reconcile(ctx context.Context, desired DNSRecordList, opts ReconcileOptions) (ReconcileResult, error)
+
+	// RenumberSubnet moves the records within oldCIDR into newPrefix, keeping host bits.
+	RenumberSubnet(ctx context.Context, oldCIDR string, newPrefix string) (changed DNSRecordList, err error)
+
+	// ImportHosts creates the records of a hosts file, skipping bad input as opts allow.
+	ImportHosts(ctx context.Context, r io.Reader, opts ImportOptions) (ImportResult, error)
+
+	// ExportJSON writes all DNS records to w as a versioned JSON backup.
+	ExportJSON(ctx context.Context, w io.Writer) error
+
+	// RestoreJSON applies a backup written by ExportJSON, merging or replacing per mode.
+	RestoreJSON(ctx context.Context, r io.Reader, mode RestoreMode) error
+
+	// PlanFromHosts diffs a hosts file against the current DNS records without applying it.
+	PlanFromHosts(ctx context.Context, r io.Reader) (toAdd, toRemove DNSRecordList, err error)
+
+	// ExportTerraformImports writes a Terraform import block for every DNS record.
+	ExportTerraformImports(ctx context.Context, resourceType string, w io.Writer) error
+
+	// VerifyResolution queries a DNS resolver, by default the Pi-hole host, for domain and
+	// reports whether expectedIP is among the answers.
+	VerifyResolution(ctx context.Context, domain string, expectedIP string, resolver string) (bool, error)
+
+	// VerifyAll resolves every DNS record and reports the domains that do not resolve as
+	// configured.
+	VerifyAll(ctx context.Context, resolver string) (map[string]error, error)
+
+	// NormalizeExisting rewrites records whose domain is not in canonical form.
+	NormalizeExisting(ctx context.Context) (int, error)
+
+	// DetectTrailingDotRecords lists the records stored with a trailing dot.
+	DetectTrailingDotRecords(ctx context.Context) (DNSRecordList, error)
 }
 
 var (
-	ErrorLocalDNSNotFound = errors.New("local dns record not found")
+	ErrorLocalDNSNotFound = fmt.Errorf("local dns record %w", ErrNotFound)
+	ErrLocalDNSUnverified = errors.New("local dns record created but not read back")
+	ErrInvalidDomain      = errors.New("invalid domain")
+	ErrInvalidIP          = errors.New("invalid IP address")
+	ErrNoUsableHostname   = errors.New("network device has no usable hostname")
 )
 
 type localDNS struct {
@@ -35,101 +132,317 @@ type localDNS struct {
 }
 
 type DNSRecord struct {
-	IP     string
-	Domain string
+	IP     string `json:"ip"`
+	Domain string `json:"domain"`
+}
+
+// DeleteValues returns the api.php query parameters that delete r, without the auth token.
+// They are exactly what Delete sends, e.g. for rebuilding a delete from a stored ID.
+func (r DNSRecord) DeleteValues() url.Values {
+	return url.Values{
+		"customdns": []string{"true"},
+		"action":    []string{"delete"},
+		"domain":    []string{r.Domain},
+		"ip":        []string{r.IP},
+	}
 }
 
 type DNSRecordList []DNSRecord
 
-type dnsRecordListResponse struct {
-	Data []dnsRecordResponseObject `json:"data"`
+// ParseRecord parses a record written either hosts style as "IP domain" or as
+// "domain -> IP". The domain is put in canonical form and both parts are validated.
+func ParseRecord(s string) (DNSRecord, error) {
+	var domain, ip string
+
+	if left, right, ok := strings.Cut(s, "->"); ok {
+		domain, ip = strings.TrimSpace(left), strings.TrimSpace(right)
+	} else {
+		fields := strings.Fields(s)
+		if len(fields) != 2 {
+			return DNSRecord{}, fmt.Errorf("malformed record %q: expected \"IP domain\" or \"domain -> IP\"", s)
+		}
+		ip, domain = fields[0], fields[1]
+	}
+
+	record := DNSRecord{Domain: normalizeDomain(domain), IP: ip}
+	if err := validateDomain(record.Domain); err != nil {
+		return DNSRecord{}, fmt.Errorf("malformed record %q: %w", s, err)
+	}
+	if err := validateIP(record.IP); err != nil {
+		return DNSRecord{}, fmt.Errorf("malformed record %q: %w", s, err)
+	}
+
+	return record, nil
 }
 
-type dnsRecordResponse struct {
-	Success       bool   `json:"success"`
-	Message       string `json:"message"`
-	FTLNotRunning bool   `json:"FTLnotrunning"`
+type dnsRecordListResponse struct {
+	Data []dnsRecordResponseObject `json:"data"`
 }
 
 type dnsRecordResponseObject []string
 
-func (record dnsRecordResponseObject) toDNSRecord() DNSRecord {
+func (record dnsRecordResponseObject) toDNSRecord() (DNSRecord, error) {
+	if len(record) != 2 {
+		return DNSRecord{}, fmt.Errorf("expected [domain, IP], got %d fields", len(record))
+	}
+
 	return DNSRecord{
 		Domain: record[0],
 		IP:     record[1],
-	}
+	}, nil
 }
 
-func (res dnsRecordListResponse) toDNSRecordList() DNSRecordList {
+func (res dnsRecordListResponse) toDNSRecordList() (DNSRecordList, error) {
 	list := make(DNSRecordList, len(res.Data))
 
 	for i, record := range res.Data {
-		list[i] = record.toDNSRecord()
+		parsed, err := record.toDNSRecord()
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		list[i] = parsed
 	}
 
-	return list
+	return list, nil
 }
 
-// List returns a list of custom DNS records
+// parseRecordListResponse parses a customdns list body of the form
+// {"data":[["domain","IP"],...]}, failing on anything else
+func parseRecordListResponse(body []byte) (DNSRecordList, error) {
+	var res *dnsRecordListResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+	if res == nil || res.Data == nil {
+		return nil, errors.New("missing data")
+	}
+
+	return res.toDNSRecordList()
+}
+
+// List returns a list of custom DNS records, served from the cache when enabled
 func (dns localDNS) List(ctx context.Context) (DNSRecordList, error) {
+	if dns.client.cache == nil {
+		return dns.fetchList(ctx)
+	}
+
+	list, generation, ok := dns.client.cache.get()
+	if ok {
+		return list, nil
+	}
+
+	list, err := dns.fetchList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dns.client.cache.set(list, generation)
+
+	return list, nil
+}
+
+// ListStable returns the custom DNS records in exactly the order of the API response, which
+// is the order the web interface shows. Unlike List, whose order is documented but could be
+// relaxed for efficiency, this order is part of the method's contract.
+func (dns localDNS) ListStable(ctx context.Context) (DNSRecordList, error) {
+	return dns.List(ctx)
+}
+
+// listFlightKey identifies the shared custom DNS list request
+const listFlightKey = "customdns"
+
+// fetchList requests the custom DNS records from the server, joining a concurrent identical
+// request when Config.ShareListRequests is set. A shared request runs detached from the
+// caller that started it, so cancelling one waiting caller does not fail the others. Once a
+// mutation invalidates the cache, later reads no longer join a request started before it.
+func (dns localDNS) fetchList(ctx context.Context) (DNSRecordList, error) {
+	if dns.client.listFlight == nil {
+		_, list, err := dns.ListRaw(ctx)
+		return list, err
+	}
+
+	ch := dns.client.listFlight.DoChan(listFlightKey, func() (interface{}, error) {
+		fetchCtx, cancel := recordContext(ctx)
+		defer cancel()
+
+		_, list, err := dns.ListRaw(fetchCtx)
+		return list, err
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return append(DNSRecordList{}, res.Val.(DNSRecordList)...), nil
+	}
+}
+
+// ListUnique returns the custom DNS records in server order, keeping only the first of
+// identical records
+func (dns localDNS) ListUnique(ctx context.Context) (DNSRecordList, error) {
+	list, err := dns.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch custom DNS records: %w", err)
+	}
+
+	seen := make(map[DNSRecord]bool, len(list))
+	unique := DNSRecordList{}
+	for _, record := range list {
+		if !seen[record] {
+			unique = append(unique, record)
+		}
+		seen[record] = true
+	}
+
+	return unique, nil
+}
+
+// ListRaw returns the custom DNS list response body exactly as the server sent it, along
+// with the records parsed from it. It always queries the server, bypassing the cache. When
+// the body cannot be parsed, the raw body is still returned with the error.
+func (dns localDNS) ListRaw(ctx context.Context) (json.RawMessage, DNSRecordList, error) {
 	req, err := dns.client.Request(ctx, url.Values{
 		"customdns": []string{"true"},
 		"action":    []string{"get"},
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	res, err := dns.client.http.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	defer res.Body.Close()
+	defer drainAndClose(res.Body)
 
-	var resList *dnsRecordListResponse
-	if err := json.NewDecoder(res.Body).Decode(&resList); err != nil {
-		return nil, fmt.Errorf("failed to parse customDNS list body: %w", err)
+	var raw json.RawMessage
+	if err := dns.client.decode(res.Body, &raw); err != nil {
+		return nil, nil, decodeError("customDNS list", err)
+	}
+
+	list, err := parseRecordListResponse(raw)
+	if err != nil {
+		return raw, nil, fmt.Errorf("failed to parse customDNS list body: %w", err)
 	}
 
-	return resList.toDNSRecordList(), nil
+	return raw, list, nil
 }
 
-// Create creates a custom DNS record
-func (dns localDNS) Create(ctx context.Context, domain string, IP string) (*DNSRecord, error) {
-	req, err := dns.client.Request(ctx, url.Values{
-		"customdns": []string{"true"},
-		"action":    []string{"add"},
-		"ip":        []string{IP},
-		"domain":    []string{domain},
-	})
+// ListFunc returns the custom DNS records for which keep returns true, in server order
+func (dns localDNS) ListFunc(ctx context.Context, keep func(DNSRecord) bool) (DNSRecordList, error) {
+	list, err := dns.List(ctx)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to fetch custom DNS records: %w", err)
 	}
 
-	res, err := dns.client.http.Do(req)
+	results := DNSRecordList{}
+	for _, record := range list {
+		if keep(record) {
+			results = append(results, record)
+		}
+	}
+
+	return results, nil
+}
+
+// ListByFamily returns the custom DNS records split into IPv4 (A) and IPv6 (AAAA) records,
+// each in server order. IPv4-mapped IPv6 addresses count as IPv4 and records whose IP does
+// not parse are left out.
+func (dns localDNS) ListByFamily(ctx context.Context) (v4, v6 DNSRecordList, err error) {
+	list, err := dns.List(ctx)
 	if err != nil {
-		return nil, err
+		return nil, nil, fmt.Errorf("failed to fetch custom DNS records: %w", err)
 	}
 
-	defer res.Body.Close()
+	v4, v6 = DNSRecordList{}, DNSRecordList{}
+	for _, record := range list {
+		addr, err := netip.ParseAddr(record.IP)
+		if err != nil {
+			continue
+		}
 
-	var dnsRes *dnsRecordResponse
-	if err := json.NewDecoder(res.Body).Decode(&dnsRes); err != nil {
-		return nil, fmt.Errorf("failed to parse customDNS response body: %w", err)
+		if addr.Unmap().Is4() {
+			v4 = append(v4, record)
+		} else {
+			v6 = append(v6, record)
+		}
 	}
 
-	if !dnsRes.Success {
-		return nil, fmt.Errorf("failed to create DNS record %s %s : %s : %w", domain, IP, dnsRes.Message, err)
+	return v4, v6, nil
+}
+
+// Domains returns the sorted set of domains that have a custom DNS record
+func (dns localDNS) Domains(ctx context.Context) ([]string, error) {
+	list, err := dns.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch custom DNS records: %w", err)
+	}
+
+	seen := make(map[string]bool, len(list))
+	domains := make([]string, 0, len(list))
+	for _, record := range list {
+		if !seen[record.Domain] {
+			seen[record.Domain] = true
+			domains = append(domains, record.Domain)
+		}
 	}
 
-	results, err := dns.GetList(ctx, domain)
+	sort.Strings(domains)
+
+	return domains, nil
+}
+
+// ExistsMany reports for each given domain whether it has a custom DNS record, using a
+// single List. The map is keyed by the domains as passed; they are matched in canonical form.
+func (dns localDNS) ExistsMany(ctx context.Context, domains []string) (map[string]bool, error) {
+	list, err := dns.List(ctx)
 	if err != nil {
+		return nil, fmt.Errorf("failed to fetch custom DNS records: %w", err)
+	}
+
+	present := make(map[string]bool, len(list))
+	for _, record := range list {
+		present[strings.TrimSuffix(record.Domain, ".")] = true
+	}
+
+	exists := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		exists[domain] = present[normalizeDomain(domain)]
+	}
+
+	return exists, nil
+}
+
+// Create creates a custom DNS record, lowercasing and IDNA-mapping the domain first. An
+// empty or malformed domain or IP is rejected before anything is sent.
+func (dns localDNS) Create(ctx context.Context, domain string, IP string) (*DNSRecord, error) {
+	domain = normalizeDomain(domain)
+
+	if err := validateDomain(domain); err != nil {
+		return nil, err
+	}
+	if err := validateIP(IP); err != nil {
+		return nil, err
+	}
+
+	if err := dns.add(ctx, domain, IP); err != nil {
+		return nil, err
+	}
+
+	results, err := dns.getByDomain(ctx, domain)
+	if err != nil {
+		if ctx.Err() != nil {
+			return &DNSRecord{Domain: domain, IP: IP}, fmt.Errorf("%w: %s %s: %w", ErrLocalDNSUnverified, domain, IP, err)
+		}
 		return nil, err
 	}
 
 	for _, record := range results {
-		if record.Domain == domain && record.IP == IP {
+		if record.Domain == domain && sameIP(record.IP, IP) {
 			return record, nil
 		}
 	}
@@ -137,9 +450,81 @@ func (dns localDNS) Create(ctx context.Context, domain string, IP string) (*DNSR
 	return nil, errors.New("record created but not found")
 }
 
+// CreateFromDevice creates <hostname>.<domainSuffix> pointing at the device's IP, using the
+// first host name the device reported that sanitizes into a valid DNS label
+func (dns localDNS) CreateFromDevice(ctx context.Context, dev NetworkDevice, domainSuffix string) (*DNSRecord, error) {
+	for i, name := range dev.Names {
+		label := sanitizeLabel(name)
+		if label == "" || i >= len(dev.IPs) {
+			continue
+		}
+
+		domain := label
+		if suffix := strings.Trim(domainSuffix, "."); suffix != "" {
+			domain = fmt.Sprintf("%s.%s", label, suffix)
+		}
+
+		return dns.Create(ctx, domain, dev.IPs[i])
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrNoUsableHostname, dev.HWAddr)
+}
+
+// sanitizeLabel turns a device host name into a lowercase DNS label by keeping its first
+// label and dropping characters not allowed in host names
+func sanitizeLabel(name string) string {
+	name, _, _ = strings.Cut(strings.ToLower(name), ".")
+
+	var b strings.Builder
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			b.WriteRune(r)
+		}
+	}
+
+	label := strings.Trim(b.String(), "-")
+	if len(label) > 63 {
+		label = strings.TrimRight(label[:63], "-")
+	}
+
+	return label
+}
+
+// add sends a single custom DNS record to the server
+func (dns localDNS) add(ctx context.Context, domain string, IP string) (err error) {
+	defer func() {
+		dns.client.InvalidateCache()
+		dns.client.audit(ctx, AuditEvent{Action: AuditCreateDNS, Domain: domain, IP: IP, Err: err})
+	}()
+
+	req, err := dns.client.Request(ctx, url.Values{
+		"customdns": []string{"true"},
+		"action":    []string{"add"},
+		"ip":        []string{IP},
+		"domain":    []string{domain},
+	})
+	if err != nil {
+		return err
+	}
+
+	res, err := dns.client.http.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer drainAndClose(res.Body)
+
+	var dnsRes apiResponse
+	if err := dns.client.decodeAndCheck(res, &dnsRes); err != nil {
+		return fmt.Errorf("failed to create DNS record %s %s: %w", domain, IP, err)
+	}
+
+	return nil
+}
+
 // Get returns first custom DNS record by its domain name
 func (dns localDNS) Get(ctx context.Context, domain string) (*DNSRecord, error) {
-	list, err := dns.GetList(ctx, domain)
+	list, err := dns.getByDomain(ctx, domain)
 	if err != nil {
 		return nil, err
 	}
@@ -151,17 +536,48 @@ func (dns localDNS) Get(ctx context.Context, domain string) (*DNSRecord, error)
 	return list[0], nil
 }
 
-// GetList returns all custom DNS records by its domain name
+// GetList returns all custom DNS records by its domain name. When domain parses as an IP
+// address it returns the records pointing at that address instead, so callers holding
+// either a name or an address can look records up the same way.
 func (dns localDNS) GetList(ctx context.Context, domain string) ([]*DNSRecord, error) {
+	if parseIP(domain) == nil {
+		return dns.getByDomain(ctx, domain)
+	}
+
 	list, err := dns.List(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch custom DNS records: %w", err)
 	}
 
 	var results []*DNSRecord
-	for _, record := range list {
-		if record.Domain == strings.ToLower(domain) {
-			results = append(results, &record)
+	for i := range list {
+		if sameIP(list[i].IP, domain) {
+			results = append(results, &list[i])
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrorLocalDNSNotFound, domain)
+	}
+
+	return results, nil
+}
+
+// getByDomain returns all custom DNS records of domain, including records stored with a
+// trailing dot, which Pi-hole keeps literally. Mutating methods use it rather than
+// GetList so that an IP passed as a domain is never widened to every record of the address.
+func (dns localDNS) getByDomain(ctx context.Context, domain string) ([]*DNSRecord, error) {
+	list, err := dns.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch custom DNS records: %w", err)
+	}
+
+	domain = normalizeDomain(domain)
+
+	var results []*DNSRecord
+	for i := range list {
+		if strings.TrimSuffix(list[i].Domain, ".") == domain {
+			results = append(results, &list[i])
 		}
 	}
 
@@ -172,9 +588,35 @@ func (dns localDNS) GetList(ctx context.Context, domain string) ([]*DNSRecord, e
 	return results, nil
 }
 
+// GetByCIDR returns all custom DNS records whose IP falls within cidr, e.g. 10.0.5.0/24
+func (dns localDNS) GetByCIDR(ctx context.Context, cidr string) (DNSRecordList, error) {
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+	}
+
+	return dns.ListFunc(ctx, func(record DNSRecord) bool {
+		ip := parseIP(record.IP)
+		return ip != nil && subnet.Contains(ip)
+	})
+}
+
+// GetByRegexp returns all custom DNS records whose domain, lowercased, matches pattern in
+// regexp syntax. The pattern is unanchored, so use ^ and $ to match whole domains.
+func (dns localDNS) GetByRegexp(ctx context.Context, pattern string) (DNSRecordList, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid domain pattern %q: %w", pattern, err)
+	}
+
+	return dns.ListFunc(ctx, func(record DNSRecord) bool {
+		return re.MatchString(strings.ToLower(record.Domain))
+	})
+}
+
 // Delete removes a custom DNS record
 func (dns localDNS) Delete(ctx context.Context, domain string) error {
-	records, err := dns.GetList(ctx, domain)
+	records, err := dns.getByDomain(ctx, domain)
 	if err != nil {
 		if errors.Is(err, ErrorLocalDNSNotFound) {
 			return nil
@@ -183,37 +625,272 @@ func (dns localDNS) Delete(ctx context.Context, domain string) error {
 	}
 
 	for _, record := range records {
-		if err := func() error {
-			req, err := dns.client.Request(ctx, url.Values{
-				"customdns": []string{"true"},
-				"action":    []string{"delete"},
-				"domain":    []string{record.Domain},
-				"ip":        []string{record.IP},
-			})
-			if err != nil {
-				return err
-			}
+		if err := dns.deleteRecord(ctx, *record); err != nil {
+			return err
+		}
+	}
 
-			res, err := dns.client.http.Do(req)
-			if err != nil {
-				return err
-			}
+	return nil
+}
 
-			defer res.Body.Close()
+// SwapIP moves domain from fromIP to toIP. The toIP record is added before the fromIP
+// record is removed, so the domain always resolves to at least one of them. It fails with
+// ErrorLocalDNSNotFound, without changing anything, when the fromIP record does not exist.
+func (dns localDNS) SwapIP(ctx context.Context, domain string, fromIP string, toIP string) error {
+	domain = normalizeDomain(domain)
 
-			var delRes dnsRecordResponse
-			if err := json.NewDecoder(res.Body).Decode(&delRes); err != nil {
-				return fmt.Errorf("failed to parse custom DNS deletion response body: %w", err)
-			}
+	if err := validateIP(toIP); err != nil {
+		return err
+	}
 
-			if !delRes.Success {
-				return fmt.Errorf("failed to delete custom DNS record %s: %s", domain, delRes.Message)
-			}
-			return nil
-		}(); err != nil {
+	records, err := dns.getByDomain(ctx, domain)
+	if err != nil && !errors.Is(err, ErrorLocalDNSNotFound) {
+		return fmt.Errorf("failed looking up custom DNS record %s for swap: %w", domain, err)
+	}
+
+	var from *DNSRecord
+	hasTo := false
+	for _, record := range records {
+		if sameIP(record.IP, fromIP) {
+			from = record
+		}
+		if sameIP(record.IP, toIP) {
+			hasTo = true
+		}
+	}
+
+	if from == nil {
+		return fmt.Errorf("%w: %s %s", ErrorLocalDNSNotFound, domain, fromIP)
+	}
+	if sameIP(from.IP, toIP) {
+		return nil
+	}
+
+	if !hasTo {
+		if err := dns.add(ctx, domain, toIP); err != nil {
 			return err
 		}
 	}
 
+	if err := dns.deleteRecord(ctx, *from); err != nil {
+		return fmt.Errorf("added %s %s but could not remove %s: %w", domain, toIP, from.IP, err)
+	}
+
+	return nil
+}
+
+// deleteRecord removes a single custom DNS record matching both its domain and IP
+func (dns localDNS) deleteRecord(ctx context.Context, record DNSRecord) (err error) {
+	defer func() {
+		dns.client.InvalidateCache()
+		dns.client.audit(ctx, AuditEvent{Action: AuditDeleteDNS, Domain: record.Domain, IP: record.IP, Err: err})
+	}()
+
+	req, err := dns.client.Request(ctx, record.DeleteValues())
+	if err != nil {
+		return err
+	}
+
+	res, err := dns.client.http.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer drainAndClose(res.Body)
+
+	var delRes apiResponse
+	if err := dns.client.decodeAndCheck(res, &delRes); err != nil {
+		return fmt.Errorf("failed to delete custom DNS record %s: %w", record.Domain, err)
+	}
+
+	return nil
+}
+
+// NormalizeExisting recreates every record whose domain is not lowercase and IDNA-mapped,
+// or ends in a dot, under its canonical domain, then removes the original. It returns how
+// many were fixed.
+func (dns localDNS) NormalizeExisting(ctx context.Context) (int, error) {
+	list, err := dns.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch custom DNS records: %w", err)
+	}
+
+	existing := make(map[DNSRecord]bool, len(list))
+	needsFix := false
+	for _, record := range list {
+		existing[record] = true
+		needsFix = needsFix || normalizeDomain(record.Domain) != record.Domain
+	}
+
+	if needsFix {
+		if err := dns.backupBeforeDelete(list); err != nil {
+			return 0, err
+		}
+	}
+
+	fixed := 0
+	for _, record := range list {
+		canonical := DNSRecord{Domain: normalizeDomain(record.Domain), IP: record.IP}
+		if canonical == record {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return fixed, err
+		}
+
+		recordCtx, cancelRecord := recordContext(ctx)
+		err := dns.normalizeRecord(recordCtx, record, canonical, existing)
+		cancelRecord()
+		if err != nil {
+			return fixed, err
+		}
+		fixed++
+	}
+
+	return fixed, nil
+}
+
+// DetectTrailingDotRecords returns the custom DNS records whose domain was stored with a
+// trailing dot, e.g. through the web interface, in server order. Lookups find them under
+// the domain without the dot; NormalizeExisting rewrites them.
+func (dns localDNS) DetectTrailingDotRecords(ctx context.Context) (DNSRecordList, error) {
+	return dns.ListFunc(ctx, func(record DNSRecord) bool {
+		return strings.HasSuffix(record.Domain, ".")
+	})
+}
+
+// normalizeRecord recreates record under its canonical domain unless that record already
+// exists, then deletes the original
+func (dns localDNS) normalizeRecord(ctx context.Context, record, canonical DNSRecord, existing map[DNSRecord]bool) error {
+	if !existing[canonical] {
+		if _, err := dns.Create(ctx, canonical.Domain, canonical.IP); err != nil {
+			return fmt.Errorf("failed to recreate custom DNS record %s as %s: %w", record.Domain, canonical.Domain, err)
+		}
+		existing[canonical] = true
+	}
+
+	return dns.deleteRecord(ctx, record)
+}
+
+// domainProfile maps domains the way resolvers look them up, without rejecting the
+// underscores Pi-hole accepts in custom records
+var domainProfile = idna.New(idna.MapForLookup(), idna.StrictDomainName(false), idna.Transitional(false))
+
+// normalizeDomain returns the canonical lowercase ASCII form of domain, falling back to
+// plain lowercasing when the domain cannot be IDNA-mapped. A single trailing dot is
+// dropped, as dnsmasq treats host.lan. and host.lan alike.
+func normalizeDomain(domain string) string {
+	domain = strings.TrimSuffix(domain, ".")
+
+	if ascii, err := domainProfile.ToASCII(domain); err == nil {
+		return ascii
+	}
+
+	return strings.ToLower(domain)
+}
+
+// ValidateRecords checks every record's domain and IP without contacting the server, with
+// the same rules as Create: a domain is valid when its canonical form is. The returned
+// error joins one error per invalid field, each naming the record index.
+func ValidateRecords(records []DNSRecord) error {
+	var errs []error
+
+	for i, record := range records {
+		if err := validateDomain(normalizeDomain(record.Domain)); err != nil {
+			errs = append(errs, fmt.Errorf("record %d: %w", i, err))
+		}
+		if err := validateIP(record.IP); err != nil {
+			errs = append(errs, fmt.Errorf("record %d: %w", i, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// normalizeRecords returns a copy of records with canonical domains, validated like Create
+// validates a single record, so batch methods accept exactly what Create accepts
+func normalizeRecords(records DNSRecordList) (DNSRecordList, error) {
+	normalized := make(DNSRecordList, len(records))
+	for i, record := range records {
+		normalized[i] = DNSRecord{Domain: normalizeDomain(record.Domain), IP: record.IP}
+	}
+
+	if err := ValidateRecords(normalized); err != nil {
+		return nil, err
+	}
+
+	return normalized, nil
+}
+
+// validateDomain mirrors the hostname rules Pi-hole applies to custom DNS domains
+func validateDomain(domain string) error {
+	if domain == "" {
+		return fmt.Errorf("%w: domain is empty", ErrInvalidDomain)
+	}
+	if len(domain) > 253 {
+		return fmt.Errorf("%w: %q is longer than 253 characters", ErrInvalidDomain, domain)
+	}
+
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("%w: %q has an empty or oversized label", ErrInvalidDomain, domain)
+		}
+
+		for _, r := range label {
+			if !isDomainRune(r) {
+				return fmt.Errorf("%w: %q contains invalid character %q", ErrInvalidDomain, domain, r)
+			}
+		}
+	}
+
 	return nil
 }
+
+func isDomainRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_'
+}
+
+// validateIP accepts IPv4 and IPv6 addresses, including IPv6 addresses with a zone index
+// such as fe80::1%eth0
+func validateIP(ip string) error {
+	if ip == "" {
+		return fmt.Errorf("%w: IP is empty", ErrInvalidIP)
+	}
+	if _, err := netip.ParseAddr(ip); err != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidIP, ip)
+	}
+
+	return nil
+}
+
+// splitZone separates an IPv6 zone index such as "%eth0" from the address before it
+func splitZone(ip string) (addr string, zone string) {
+	if i := strings.LastIndexByte(ip, '%'); i >= 0 {
+		return ip[:i], ip[i:]
+	}
+
+	return ip, ""
+}
+
+// parseIP parses an address like net.ParseIP, ignoring an IPv6 zone index
+func parseIP(ip string) net.IP {
+	if _, err := netip.ParseAddr(ip); err != nil {
+		return nil
+	}
+
+	addr, _ := splitZone(ip)
+	return net.ParseIP(addr)
+}
+
+// sameIP reports whether two addresses are equal regardless of their textual form.
+// IPv6 zone indices are part of the address, so fe80::1%eth0 and fe80::1 differ.
+func sameIP(a, b string) bool {
+	addrA, errA := netip.ParseAddr(a)
+	addrB, errB := netip.ParseAddr(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+
+	return addrA.Unmap() == addrB.Unmap()
+}
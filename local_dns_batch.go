@@ -0,0 +1,512 @@
+package pihole
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+var (
+	ErrTooManyDeletes = errors.New("batch exceeds maximum number of deletes")
+)
+
+// BatchResult holds the outcome of a bulk operation
+type BatchResult struct {
+	// Records holds the records successfully processed, even when the operation stopped early
+	Records DNSRecordList
+}
+
+// DeleteManyOptions controls the safety checks applied by DeleteMany
+type DeleteManyOptions struct {
+	// MaxDeletes refuses the whole batch when it would delete more records than this.
+	// Zero disables the cap.
+	MaxDeletes int
+
+	// Force deletes the batch even when it exceeds MaxDeletes
+	Force bool
+}
+
+// upsertRecord adds record, then deletes the domain's stale records that are not desired,
+// keeping existing up to date
+func (dns localDNS) upsertRecord(ctx context.Context, record DNSRecord, stale DNSRecordList, desired, existing map[DNSRecord]bool) error {
+	if err := dns.add(ctx, record.Domain, record.IP); err != nil {
+		return err
+	}
+	existing[record] = true
+
+	for _, old := range stale {
+		if desired[old] || !existing[old] {
+			continue
+		}
+		if err := dns.deleteRecord(ctx, old); err != nil {
+			return err
+		}
+		existing[old] = false
+	}
+
+	return nil
+}
+
+// upsertDeletes reports whether upserting records would delete any stale record
+func upsertDeletes(records DNSRecordList, byDomain map[string]DNSRecordList, desired, existing map[DNSRecord]bool) bool {
+	for _, record := range records {
+		if existing[record] {
+			continue
+		}
+		for _, old := range byDomain[record.Domain] {
+			if !desired[old] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ReconcileOptions controls how Reconcile converges the server onto the desired records.
+// Records cannot carry an owner tag in Pi-hole, so every record on the server is treated as
+// managed; use AllowDelete only when desired is the complete list.
+type ReconcileOptions struct {
+	// AllowDelete removes records that are not desired, including stale IPs of desired
+	// domains. Without it, Reconcile only adds.
+	AllowDelete bool
+
+	// DryRun computes the result without changing anything
+	DryRun bool
+}
+
+// ReconcileResult counts the records Reconcile changed, or would change in a dry run
+type ReconcileResult struct {
+	// Created counts desired records whose domain had no records yet
+	Created int
+
+	// Updated counts desired records added to a domain that already had other records
+	Updated int
+
+	// Deleted counts records removed because they were not desired
+	Deleted int
+
+	// Unchanged counts desired records that were already present
+	Unchanged int
+}
+
+// Progress reports one record processed by CreateManyWithProgress
+type Progress struct {
+	// Done counts the records processed so far, including Current
+	Done int
+
+	// Total is the number of records in the batch
+	Total int
+
+	// Current is the record just processed
+	Current DNSRecord
+
+	// Err is the error creating Current, if any; the batch stops after it
+	Err error
+}
+
+// CreateMany validates all records up front, then creates them one at a time. When ctx is
+// cancelled or a record fails, the records created so far are returned with the error.
+// Cancellation is only checked between records: the record in flight is finished, so
+// cancelling after N records leaves exactly those N created.
+func (dns localDNS) CreateMany(ctx context.Context, records DNSRecordList) (*BatchResult, error) {
+	return dns.createMany(ctx, records, nil)
+}
+
+// CreateManyWithProgress behaves like CreateMany and sends a Progress on progress after each
+// record. Sends never block: an update is dropped when the channel is not ready, so give it
+// a buffer of len(records) to receive every update. No update is sent when the batch stops
+// on validation or on cancellation between records, so the returned result and error are
+// authoritative. The channel is not closed.
+func (dns localDNS) CreateManyWithProgress(ctx context.Context, records DNSRecordList, progress chan<- Progress) (*BatchResult, error) {
+	return dns.createMany(ctx, records, progress)
+}
+
+// createMany implements CreateMany, reporting to progress unless it is nil
+func (dns localDNS) createMany(ctx context.Context, records DNSRecordList, progress chan<- Progress) (*BatchResult, error) {
+	ctx, cancel := dns.client.operationContext(ctx)
+	defer cancel()
+
+	result := &BatchResult{}
+
+	records, err := normalizeRecords(records)
+	if err != nil {
+		return result, err
+	}
+
+	for i, record := range records {
+		if err := ctx.Err(); err != nil {
+			return result, fmt.Errorf("created %d of %d DNS records: %w", len(result.Records), len(records), err)
+		}
+
+		recordCtx, cancelRecord := recordContext(ctx)
+		created, err := dns.Create(recordCtx, record.Domain, record.IP)
+		cancelRecord()
+		if created != nil {
+			result.Records = append(result.Records, *created)
+		}
+
+		if progress != nil {
+			select {
+			case progress <- Progress{Done: i + 1, Total: len(records), Current: record, Err: err}:
+			default:
+			}
+		}
+
+		if err != nil {
+			return result, fmt.Errorf("created %d of %d DNS records: %w", len(result.Records), len(records), err)
+		}
+	}
+
+	return result, nil
+}
+
+// Upsert ensures every given record exists without touching unrelated domains. A record
+// whose domain has no records yet is created. A record whose domain exists with other IPs
+// is updated: it is added and the domain's IPs not among the given records are deleted.
+// Records already present are left alone.
+func (dns localDNS) Upsert(ctx context.Context, records DNSRecordList) (created, updated DNSRecordList, err error) {
+	ctx, cancel := dns.client.operationContext(ctx)
+	defer cancel()
+
+	normalized, err := normalizeRecords(records)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	list, err := dns.List(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch custom DNS records: %w", err)
+	}
+
+	existing := make(map[DNSRecord]bool, len(list))
+	byDomain := make(map[string]DNSRecordList)
+	for _, record := range list {
+		existing[record] = true
+		byDomain[record.Domain] = append(byDomain[record.Domain], record)
+	}
+
+	desired := make(map[DNSRecord]bool, len(normalized))
+	for _, record := range normalized {
+		desired[record] = true
+	}
+
+	if upsertDeletes(normalized, byDomain, desired, existing) {
+		if err := dns.backupBeforeDelete(list); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, record := range normalized {
+		if err := ctx.Err(); err != nil {
+			return created, updated, fmt.Errorf("upserted %d of %d DNS records: %w", len(created)+len(updated), len(records), err)
+		}
+		if existing[record] {
+			continue
+		}
+
+		recordCtx, cancelRecord := recordContext(ctx)
+		err := dns.upsertRecord(recordCtx, record, byDomain[record.Domain], desired, existing)
+		cancelRecord()
+		if err != nil {
+			return created, updated, fmt.Errorf("upserted %d of %d DNS records: %w", len(created)+len(updated), len(records), err)
+		}
+
+		if len(byDomain[record.Domain]) == 0 {
+			created = append(created, record)
+		} else {
+			updated = append(updated, record)
+		}
+	}
+
+	return created, updated, nil
+}
+
+// DeleteMany deletes every record of the given domains. The records to delete are resolved
+// with a single List before anything is removed, so MaxDeletes is checked against the real
+// count. When ctx is cancelled or a delete fails, the records deleted so far are returned.
+func (dns localDNS) DeleteMany(ctx context.Context, domains []string, opts DeleteManyOptions) (*BatchResult, error) {
+	ctx, cancel := dns.client.operationContext(ctx)
+	defer cancel()
+
+	result := &BatchResult{}
+
+	list, err := dns.List(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to fetch custom DNS records: %w", err)
+	}
+
+	wanted := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		wanted[normalizeDomain(domain)] = true
+	}
+
+	var records DNSRecordList
+	for _, record := range list {
+		if wanted[strings.TrimSuffix(record.Domain, ".")] {
+			records = append(records, record)
+		}
+	}
+
+	if opts.MaxDeletes > 0 && len(records) > opts.MaxDeletes && !opts.Force {
+		return result, fmt.Errorf("%w: %d records matched, limit is %d", ErrTooManyDeletes, len(records), opts.MaxDeletes)
+	}
+
+	if len(records) > 0 {
+		if err := dns.backupBeforeDelete(list); err != nil {
+			return result, err
+		}
+	}
+
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return result, fmt.Errorf("deleted %d of %d DNS records: %w", len(result.Records), len(records), err)
+		}
+
+		recordCtx, cancelRecord := recordContext(ctx)
+		err := dns.deleteRecord(recordCtx, record)
+		cancelRecord()
+		if err != nil {
+			return result, fmt.Errorf("deleted %d of %d DNS records: %w", len(result.Records), len(records), err)
+		}
+		result.Records = append(result.Records, record)
+	}
+
+	return result, nil
+}
+
+// DeleteByIP deletes every record pointing at ip, whatever its domain, and returns how many
+// were deleted, including those deleted before a failure or cancellation. Addresses are
+// compared in parsed form, so fd00::1 also matches fd00:0:0::1.
+func (dns localDNS) DeleteByIP(ctx context.Context, ip string) (int, error) {
+	ctx, cancel := dns.client.operationContext(ctx)
+	defer cancel()
+
+	if err := validateIP(ip); err != nil {
+		return 0, err
+	}
+
+	list, err := dns.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch custom DNS records: %w", err)
+	}
+
+	var records DNSRecordList
+	for _, record := range list {
+		if sameIP(record.IP, ip) {
+			records = append(records, record)
+		}
+	}
+
+	if len(records) > 0 {
+		if err := dns.backupBeforeDelete(list); err != nil {
+			return 0, err
+		}
+	}
+
+	deleted := 0
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return deleted, fmt.Errorf("deleted %d of %d DNS records: %w", deleted, len(records), err)
+		}
+
+		recordCtx, cancelRecord := recordContext(ctx)
+		err := dns.deleteRecord(recordCtx, record)
+		cancelRecord()
+		if err != nil {
+			return deleted, fmt.Errorf("deleted %d of %d DNS records: %w", deleted, len(records), err)
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// DiffRecords compares two record lists, returning the records of desired missing from
+// current and the records of current missing from desired
+func DiffRecords(current, desired DNSRecordList) (toAdd, toRemove DNSRecordList) {
+	inCurrent := make(map[DNSRecord]bool, len(current))
+	for _, record := range current {
+		inCurrent[record] = true
+	}
+
+	inDesired := make(map[DNSRecord]bool, len(desired))
+	for _, record := range desired {
+		if !inCurrent[record] && !inDesired[record] {
+			toAdd = append(toAdd, record)
+		}
+		inDesired[record] = true
+	}
+
+	for _, record := range current {
+		if !inDesired[record] {
+			toRemove = append(toRemove, record)
+		}
+	}
+
+	return toAdd, toRemove
+}
+
+// RenumberSubnet rewrites every record within oldCIDR to the same host address within
+// newPrefix, which must have the same prefix length, e.g. 10.0.5.0/24 to 10.1.0.0/24. Each
+// record is added under its new IP before the old one is deleted. The renumbered records
+// are returned, including those done before a failure or cancellation.
+func (dns localDNS) RenumberSubnet(ctx context.Context, oldCIDR string, newPrefix string) (changed DNSRecordList, err error) {
+	ctx, cancel := dns.client.operationContext(ctx)
+	defer cancel()
+
+	_, oldNet, err := net.ParseCIDR(oldCIDR)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %q: %w", oldCIDR, err)
+	}
+
+	_, newNet, err := net.ParseCIDR(newPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %q: %w", newPrefix, err)
+	}
+
+	oldOnes, oldBits := oldNet.Mask.Size()
+	newOnes, newBits := newNet.Mask.Size()
+	if oldOnes != newOnes || oldBits != newBits {
+		return nil, fmt.Errorf("cannot renumber %s into %s: prefixes differ in length or family", oldCIDR, newPrefix)
+	}
+
+	list, err := dns.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch custom DNS records: %w", err)
+	}
+
+	var records DNSRecordList
+	for _, record := range list {
+		if ip := parseIP(record.IP); ip != nil && oldNet.Contains(ip) {
+			records = append(records, record)
+		}
+	}
+
+	if len(records) > 0 {
+		if err := dns.backupBeforeDelete(list); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return changed, fmt.Errorf("renumbered %d of %d DNS records: %w", len(changed), len(records), err)
+		}
+
+		ip := parseIP(record.IP)
+		if oldBits == 8*net.IPv4len {
+			ip = ip.To4()
+		}
+
+		renumbered := make(net.IP, len(ip))
+		for i := range ip {
+			renumbered[i] = newNet.IP[i] | ip[i]&^newNet.Mask[i]
+		}
+
+		_, zone := splitZone(record.IP)
+		moved := DNSRecord{Domain: record.Domain, IP: renumbered.String() + zone}
+		recordCtx, cancelRecord := recordContext(ctx)
+		err := dns.add(recordCtx, moved.Domain, moved.IP)
+		if err == nil {
+			err = dns.deleteRecord(recordCtx, record)
+		}
+		cancelRecord()
+		if err != nil {
+			return changed, fmt.Errorf("renumbered %d of %d DNS records: %w", len(changed), len(records), err)
+		}
+
+		changed = append(changed, moved)
+	}
+
+	return changed, nil
+}
+
+// Reconcile converges the custom DNS records onto desired with a single List, adding the
+// missing records first and then, when opts.AllowDelete is set, deleting the undesired
+// ones. When ctx is cancelled or a change fails, the counts of the changes made so far are
+// returned with the error.
+func (dns localDNS) Reconcile(ctx context.Context, desired DNSRecordList, opts ReconcileOptions) (ReconcileResult, error) {
+	ctx, cancel := dns.client.operationContext(ctx)
+	defer cancel()
+
+	var result ReconcileResult
+
+	normalized, err := normalizeRecords(desired)
+	if err != nil {
+		return result, err
+	}
+
+	current, err := dns.List(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to fetch custom DNS records: %w", err)
+	}
+
+	toAdd, toRemove := DiffRecords(current, normalized)
+	if !opts.AllowDelete {
+		toRemove = nil
+	}
+
+	hasRecords := make(map[string]bool, len(current))
+	present := make(map[DNSRecord]bool, len(current))
+	for _, record := range current {
+		hasRecords[record.Domain] = true
+		present[record] = true
+	}
+
+	counted := make(map[DNSRecord]bool, len(normalized))
+	for _, record := range normalized {
+		if present[record] && !counted[record] {
+			result.Unchanged++
+		}
+		counted[record] = true
+	}
+
+	if len(toRemove) > 0 && !opts.DryRun {
+		if err := dns.backupBeforeDelete(current); err != nil {
+			return result, err
+		}
+	}
+
+	for _, record := range toAdd {
+		if err := ctx.Err(); err != nil {
+			return result, fmt.Errorf("reconciled %d changes: %w", result.Created+result.Updated+result.Deleted, err)
+		}
+
+		if !opts.DryRun {
+			recordCtx, cancelRecord := recordContext(ctx)
+			err := dns.add(recordCtx, record.Domain, record.IP)
+			cancelRecord()
+			if err != nil {
+				return result, fmt.Errorf("reconciled %d changes: %w", result.Created+result.Updated+result.Deleted, err)
+			}
+		}
+
+		if hasRecords[record.Domain] {
+			result.Updated++
+		} else {
+			result.Created++
+		}
+	}
+
+	for _, record := range toRemove {
+		if err := ctx.Err(); err != nil {
+			return result, fmt.Errorf("reconciled %d changes: %w", result.Created+result.Updated+result.Deleted, err)
+		}
+
+		if !opts.DryRun {
+			recordCtx, cancelRecord := recordContext(ctx)
+			err := dns.deleteRecord(recordCtx, record)
+			cancelRecord()
+			if err != nil {
+				return result, fmt.Errorf("reconciled %d changes: %w", result.Created+result.Updated+result.Deleted, err)
+			}
+		}
+
+		result.Deleted++
+	}
+
+	return result, nil
+}
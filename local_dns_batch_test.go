@@ -0,0 +1,478 @@
+package pihole
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalDNSCreateMany(t *testing.T) {
+	t.Run("create all records", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		records := DNSRecordList{
+			{Domain: "a.lan", IP: "127.0.0.1"},
+			{Domain: "b.lan", IP: "127.0.0.2"},
+		}
+
+		result, err := c.LocalDNS.CreateMany(context.Background(), records)
+		require.NoError(t, err)
+
+		assert.Equal(t, records, result.Records)
+		assert.Equal(t, records, fake.list())
+	})
+
+	t.Run("return partial result on cancel", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			fake.ServeHTTP(w, r)
+			if r.URL.Query().Get("action") == "get" {
+				cancel()
+			}
+		})
+
+		result, err := c.LocalDNS.CreateMany(ctx, DNSRecordList{
+			{Domain: "a.lan", IP: "127.0.0.1"},
+			{Domain: "b.lan", IP: "127.0.0.2"},
+		})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, DNSRecordList{{Domain: "a.lan", IP: "127.0.0.1"}}, result.Records)
+	})
+
+	t.Run("finish the record in flight on cancel", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			if q := r.URL.Query(); q.Get("action") == "add" && q.Get("domain") == "b.lan" {
+				cancel()
+				time.Sleep(20 * time.Millisecond)
+			}
+			fake.ServeHTTP(w, r)
+		})
+
+		result, err := c.LocalDNS.CreateMany(ctx, DNSRecordList{
+			{Domain: "a.lan", IP: "127.0.0.1"},
+			{Domain: "b.lan", IP: "127.0.0.2"},
+			{Domain: "c.lan", IP: "127.0.0.3"},
+		})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, DNSRecordList{
+			{Domain: "a.lan", IP: "127.0.0.1"},
+			{Domain: "b.lan", IP: "127.0.0.2"},
+		}, result.Records)
+		assert.Equal(t, result.Records, fake.list())
+	})
+
+	t.Run("stop when the operation timeout elapses", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{OperationTimeout: 150 * time.Millisecond}, func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(40 * time.Millisecond)
+			fake.ServeHTTP(w, r)
+		})
+
+		result, err := c.LocalDNS.CreateMany(context.Background(), DNSRecordList{
+			{Domain: "a.lan", IP: "127.0.0.1"},
+			{Domain: "b.lan", IP: "127.0.0.2"},
+			{Domain: "c.lan", IP: "127.0.0.3"},
+			{Domain: "d.lan", IP: "127.0.0.4"},
+		})
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, len(result.Records), 4)
+	})
+
+	t.Run("accept the domains create accepts", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		result, err := c.LocalDNS.CreateMany(context.Background(), DNSRecordList{
+			{Domain: "x.lan.", IP: "127.0.0.1"},
+			{Domain: "bücher.lan", IP: "127.0.0.2"},
+		})
+		require.NoError(t, err)
+
+		want := DNSRecordList{
+			{Domain: "x.lan", IP: "127.0.0.1"},
+			{Domain: "xn--bcher-kva.lan", IP: "127.0.0.2"},
+		}
+		assert.Equal(t, want, result.Records)
+		assert.Equal(t, want, fake.list())
+	})
+
+	t.Run("error on invalid records without creating any", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		result, err := c.LocalDNS.CreateMany(context.Background(), DNSRecordList{
+			{Domain: "a.lan", IP: "127.0.0.1"},
+			{Domain: "", IP: "127.0.0.2"},
+		})
+
+		assert.ErrorIs(t, err, ErrInvalidDomain)
+		assert.Empty(t, result.Records)
+		assert.Empty(t, fake.list())
+	})
+}
+
+func TestLocalDNSCreateManyWithProgress(t *testing.T) {
+	records := DNSRecordList{
+		{Domain: "a.lan", IP: "127.0.0.1"},
+		{Domain: "b.lan", IP: "127.0.0.2"},
+	}
+
+	t.Run("send progress after each record", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, newFakeLocalDNS().ServeHTTP)
+
+		progress := make(chan Progress, len(records))
+		result, err := c.LocalDNS.CreateManyWithProgress(context.Background(), records, progress)
+		require.NoError(t, err)
+		close(progress)
+
+		var updates []Progress
+		for update := range progress {
+			updates = append(updates, update)
+		}
+
+		assert.Equal(t, records, result.Records)
+		assert.Equal(t, []Progress{
+			{Done: 1, Total: 2, Current: records[0]},
+			{Done: 2, Total: 2, Current: records[1]},
+		}, updates)
+	})
+
+	t.Run("drop updates instead of blocking on an unread channel", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		done := make(chan *BatchResult, 1)
+		go func() {
+			result, err := c.LocalDNS.CreateManyWithProgress(context.Background(), records, make(chan Progress))
+			assert.NoError(t, err)
+			done <- result
+		}()
+
+		select {
+		case result := <-done:
+			assert.Equal(t, records, result.Records)
+		case <-time.After(5 * time.Second):
+			t.Fatal("blocked on an unread channel")
+		}
+		assert.Equal(t, records, fake.list())
+	})
+}
+
+func TestLocalDNSDeleteMany(t *testing.T) {
+	records := []DNSRecord{
+		{Domain: "a.lan", IP: "127.0.0.1"},
+		{Domain: "a.lan", IP: "::1"},
+		{Domain: "b.lan", IP: "127.0.0.2"},
+		{Domain: "c.lan", IP: "127.0.0.3"},
+	}
+
+	t.Run("delete all records of the domains", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(records...)
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		result, err := c.LocalDNS.DeleteMany(context.Background(), []string{"A.lan", "b.lan", "missing.lan"}, DeleteManyOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, DNSRecordList(records[:3]), result.Records)
+		assert.Equal(t, DNSRecordList{records[3]}, fake.list())
+	})
+
+	t.Run("delete records stored with a trailing dot", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(DNSRecord{Domain: "host.lan.", IP: "127.0.0.1"})
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		result, err := c.LocalDNS.DeleteMany(context.Background(), []string{"host.lan"}, DeleteManyOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, DNSRecordList{{Domain: "host.lan.", IP: "127.0.0.1"}}, result.Records)
+		assert.Empty(t, fake.list())
+	})
+
+	t.Run("leave exactly the deleted records applied on cancel", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		fake := newFakeLocalDNS(records...)
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			if q := r.URL.Query(); q.Get("action") == "delete" && q.Get("ip") == "::1" {
+				cancel()
+				time.Sleep(20 * time.Millisecond)
+			}
+			fake.ServeHTTP(w, r)
+		})
+
+		result, err := c.LocalDNS.DeleteMany(ctx, []string{"a.lan", "b.lan"}, DeleteManyOptions{})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, DNSRecordList(records[:2]), result.Records)
+		assert.Equal(t, DNSRecordList(records[2:]), fake.list())
+	})
+
+	t.Run("error when exceeding max deletes", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(records...)
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		_, err := c.LocalDNS.DeleteMany(context.Background(), []string{"a.lan", "b.lan"}, DeleteManyOptions{MaxDeletes: 2})
+
+		assert.ErrorIs(t, err, ErrTooManyDeletes)
+		assert.Equal(t, DNSRecordList(records), fake.list())
+	})
+
+	t.Run("delete past max deletes when forced", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(records...)
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		result, err := c.LocalDNS.DeleteMany(context.Background(), []string{"a.lan", "b.lan"}, DeleteManyOptions{MaxDeletes: 2, Force: true})
+		require.NoError(t, err)
+
+		assert.Len(t, result.Records, 3)
+	})
+}
+
+func TestLocalDNSRenumberSubnet(t *testing.T) {
+	t.Run("move records into the new prefix", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(
+			DNSRecord{Domain: "a.lan", IP: "10.0.5.1"},
+			DNSRecord{Domain: "b.lan", IP: "10.0.6.1"},
+			DNSRecord{Domain: "c.lan", IP: "10.0.5.200"},
+		)
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		changed, err := c.LocalDNS.RenumberSubnet(context.Background(), "10.0.5.0/24", "192.168.7.0/24")
+		require.NoError(t, err)
+
+		assert.Equal(t, DNSRecordList{
+			{Domain: "a.lan", IP: "192.168.7.1"},
+			{Domain: "c.lan", IP: "192.168.7.200"},
+		}, changed)
+		assert.ElementsMatch(t, DNSRecordList{
+			{Domain: "b.lan", IP: "10.0.6.1"},
+			{Domain: "a.lan", IP: "192.168.7.1"},
+			{Domain: "c.lan", IP: "192.168.7.200"},
+		}, fake.list())
+	})
+
+	t.Run("move IPv6 records into the new prefix", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(DNSRecord{Domain: "a.lan", IP: "fd00:1::42"})
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		changed, err := c.LocalDNS.RenumberSubnet(context.Background(), "fd00:1::/64", "fd00:2::/64")
+		require.NoError(t, err)
+
+		assert.Equal(t, DNSRecordList{{Domain: "a.lan", IP: "fd00:2::42"}}, changed)
+	})
+
+	t.Run("error on prefixes of different length", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(DNSRecord{Domain: "a.lan", IP: "10.0.5.1"})
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		_, err := c.LocalDNS.RenumberSubnet(context.Background(), "10.0.5.0/24", "10.1.0.0/16")
+
+		assert.Error(t, err)
+		assert.Equal(t, DNSRecordList{{Domain: "a.lan", IP: "10.0.5.1"}}, fake.list())
+	})
+}
+
+func TestLocalDNSUpsert(t *testing.T) {
+	t.Run("create, update and keep records", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(
+			DNSRecord{Domain: "same.lan", IP: "10.0.0.1"},
+			DNSRecord{Domain: "moved.lan", IP: "10.0.0.2"},
+			DNSRecord{Domain: "other.lan", IP: "10.0.0.3"},
+		)
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		created, updated, err := c.LocalDNS.Upsert(context.Background(), DNSRecordList{
+			{Domain: "same.lan", IP: "10.0.0.1"},
+			{Domain: "Moved.lan", IP: "10.0.1.2"},
+			{Domain: "new.lan", IP: "10.0.0.4"},
+			{Domain: "new.lan", IP: "fd00::4"},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, DNSRecordList{
+			{Domain: "new.lan", IP: "10.0.0.4"},
+			{Domain: "new.lan", IP: "fd00::4"},
+		}, created)
+		assert.Equal(t, DNSRecordList{{Domain: "moved.lan", IP: "10.0.1.2"}}, updated)
+		assert.ElementsMatch(t, DNSRecordList{
+			{Domain: "same.lan", IP: "10.0.0.1"},
+			{Domain: "other.lan", IP: "10.0.0.3"},
+			{Domain: "moved.lan", IP: "10.0.1.2"},
+			{Domain: "new.lan", IP: "10.0.0.4"},
+			{Domain: "new.lan", IP: "fd00::4"},
+		}, fake.list())
+	})
+}
+
+func TestLocalDNSReconcile(t *testing.T) {
+	newReconcileFake := func() *fakeLocalDNS {
+		return newFakeLocalDNS(
+			DNSRecord{Domain: "keep.lan", IP: "10.0.0.1"},
+			DNSRecord{Domain: "move.lan", IP: "10.0.0.2"},
+			DNSRecord{Domain: "gone.lan", IP: "10.0.0.3"},
+		)
+	}
+	desired := DNSRecordList{
+		{Domain: "keep.lan", IP: "10.0.0.1"},
+		{Domain: "Move.lan", IP: "10.0.0.20"},
+		{Domain: "new.lan", IP: "10.0.0.4"},
+	}
+
+	t.Run("converge onto the desired records", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newReconcileFake()
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		result, err := c.LocalDNS.Reconcile(context.Background(), desired, ReconcileOptions{AllowDelete: true})
+		require.NoError(t, err)
+
+		assert.Equal(t, ReconcileResult{Created: 1, Updated: 1, Deleted: 2, Unchanged: 1}, result)
+		assert.ElementsMatch(t, DNSRecordList{
+			{Domain: "keep.lan", IP: "10.0.0.1"},
+			{Domain: "move.lan", IP: "10.0.0.20"},
+			{Domain: "new.lan", IP: "10.0.0.4"},
+		}, fake.list())
+	})
+
+	t.Run("only add without AllowDelete", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newReconcileFake()
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		result, err := c.LocalDNS.Reconcile(context.Background(), desired, ReconcileOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, ReconcileResult{Created: 1, Updated: 1, Unchanged: 1}, result)
+		assert.Len(t, fake.list(), 5)
+	})
+
+	t.Run("change nothing in a dry run", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newReconcileFake()
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		result, err := c.LocalDNS.Reconcile(context.Background(), desired, ReconcileOptions{AllowDelete: true, DryRun: true})
+		require.NoError(t, err)
+
+		assert.Equal(t, ReconcileResult{Created: 1, Updated: 1, Deleted: 2, Unchanged: 1}, result)
+		assert.Equal(t, newReconcileFake().list(), fake.list())
+	})
+
+	t.Run("reject invalid desired records", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newReconcileFake()
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		_, err := c.LocalDNS.Reconcile(context.Background(), DNSRecordList{{Domain: "bad..lan", IP: "10.0.0.1"}}, ReconcileOptions{})
+		assert.ErrorIs(t, err, ErrInvalidDomain)
+	})
+}
+
+func TestLocalDNSDeleteByIP(t *testing.T) {
+	t.Run("delete every record of the IP", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(
+			DNSRecord{Domain: "a.lan", IP: "fd00::1"},
+			DNSRecord{Domain: "b.lan", IP: "fd00:0:0::1"},
+			DNSRecord{Domain: "c.lan", IP: "fd00::2"},
+			DNSRecord{Domain: "d.lan", IP: "10.0.0.1"},
+		)
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		deleted, err := c.LocalDNS.DeleteByIP(context.Background(), "FD00::1")
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, deleted)
+		assert.Equal(t, DNSRecordList{
+			{Domain: "c.lan", IP: "fd00::2"},
+			{Domain: "d.lan", IP: "10.0.0.1"},
+		}, fake.list())
+	})
+
+	t.Run("reject an invalid IP", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, newFakeLocalDNS().ServeHTTP)
+
+		_, err := c.LocalDNS.DeleteByIP(context.Background(), "10.0.0")
+		assert.ErrorIs(t, err, ErrInvalidIP)
+	})
+}
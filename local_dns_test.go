@@ -2,9 +2,16 @@ package pihole
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -29,6 +36,59 @@ func cleanupDNS(t *testing.T, c *Client, domain string) {
 	}
 }
 
+// fakeLocalDNS serves the customdns actions of api.php from an in-memory record list
+type fakeLocalDNS struct {
+	mu      sync.Mutex
+	records [][]string
+}
+
+func newFakeLocalDNS(records ...DNSRecord) *fakeLocalDNS {
+	fake := &fakeLocalDNS{records: [][]string{}}
+	for _, record := range records {
+		fake.records = append(fake.records, []string{record.Domain, record.IP})
+	}
+
+	return fake
+}
+
+func (f *fakeLocalDNS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	q := r.URL.Query()
+	domain, ip := q.Get("domain"), q.Get("ip")
+
+	switch q.Get("action") {
+	case "get":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": f.records})
+	case "add":
+		f.records = append(f.records, []string{domain, ip})
+		fmt.Fprint(w, `{"success":true,"message":""}`)
+	case "delete":
+		for i, record := range f.records {
+			if record[0] == domain && record[1] == ip {
+				f.records = append(f.records[:i], f.records[i+1:]...)
+				fmt.Fprint(w, `{"success":true,"message":""}`)
+				return
+			}
+		}
+		fmt.Fprint(w, `{"success":false,"message":"This domain/ip association does not exist"}`)
+	}
+}
+
+// list returns the records currently held by the fake server
+func (f *fakeLocalDNS) list() DNSRecordList {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	list := make(DNSRecordList, len(f.records))
+	for i, record := range f.records {
+		list[i] = DNSRecord{Domain: record[0], IP: record[1]}
+	}
+
+	return list
+}
+
 func TestLocalDNS(t *testing.T) {
 	t.Run("Test create a DNS record", func(t *testing.T) {
 		isAcceptance(t)
@@ -68,3 +128,956 @@ func TestLocalDNS(t *testing.T) {
 		assert.ErrorIs(t, err, ErrorLocalDNSNotFound)
 	})
 }
+
+func TestDNSRecordJSON(t *testing.T) {
+	t.Run("marshal with lowercase keys", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		b, err := json.Marshal(DNSRecord{Domain: "test.lan", IP: "127.0.0.1"})
+		require.NoError(t, err)
+
+		assert.JSONEq(t, `{"domain":"test.lan","ip":"127.0.0.1"}`, string(b))
+	})
+}
+
+func TestDNSRecordDeleteValues(t *testing.T) {
+	t.Run("match the parameters sent by delete", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		record := DNSRecord{Domain: "test.lan", IP: "127.0.0.1"}
+		fake := newFakeLocalDNS(record)
+
+		var sent url.Values
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			if q := r.URL.Query(); q.Get("action") == "delete" {
+				q.Del("auth")
+				sent = q
+			}
+			fake.ServeHTTP(w, r)
+		})
+
+		require.NoError(t, c.LocalDNS.Delete(context.Background(), record.Domain))
+
+		assert.Equal(t, record.DeleteValues(), sent)
+	})
+}
+
+func TestParseRecordListResponse(t *testing.T) {
+	t.Run("parse domain and IP pairs", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		list, err := parseRecordListResponse([]byte(`{"data":[["a.lan","10.0.0.1"],["b.lan","fd00::1"]]}`))
+		require.NoError(t, err)
+
+		assert.Equal(t, DNSRecordList{
+			{Domain: "a.lan", IP: "10.0.0.1"},
+			{Domain: "b.lan", IP: "fd00::1"},
+		}, list)
+	})
+
+	t.Run("error on malformed bodies", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		for _, body := range []string{
+			``,
+			`null`,
+			`{}`,
+			`{"data":null}`,
+			`{"data":[["a.lan"]]}`,
+			`{"data":[[]]}`,
+			`{"data":[["a.lan","10.0.0.1","extra"]]}`,
+			`{"data":[[1,2]]}`,
+			`{"data":"a.lan"}`,
+		} {
+			_, err := parseRecordListResponse([]byte(body))
+			assert.Error(t, err, body)
+		}
+	})
+}
+
+func FuzzParseRecordList(f *testing.F) {
+	for _, seed := range []string{
+		`{"data":[["a.lan","10.0.0.1"]]}`,
+		`{"data":[]}`,
+		`{"data":[["a.lan"]]}`,
+		`{"data":[[],["b.lan","::1"]]}`,
+		`[]`,
+		`{"data":null}`,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		list, err := parseRecordListResponse(body)
+		if err != nil {
+			assert.Nil(t, list)
+			return
+		}
+
+		assert.True(t, json.Valid(body))
+
+		var res struct {
+			Data [][]string `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(body, &res))
+		require.Len(t, list, len(res.Data))
+		for i, row := range res.Data {
+			assert.Equal(t, DNSRecord{Domain: row[0], IP: row[1]}, list[i])
+		}
+	})
+}
+
+func TestParseRecord(t *testing.T) {
+	t.Run("parse hosts style", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		record, err := ParseRecord("10.0.0.1  NAS.lan")
+		require.NoError(t, err)
+		assert.Equal(t, DNSRecord{Domain: "nas.lan", IP: "10.0.0.1"}, record)
+	})
+
+	t.Run("parse arrow style", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		record, err := ParseRecord("nas.lan -> fe80::1%eth0")
+		require.NoError(t, err)
+		assert.Equal(t, DNSRecord{Domain: "nas.lan", IP: "fe80::1%eth0"}, record)
+	})
+
+	t.Run("reject malformed input", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		_, err := ParseRecord("10.0.0.1")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `"10.0.0.1"`)
+
+		_, err = ParseRecord("nas.lan -> 10.0.0")
+		assert.ErrorIs(t, err, ErrInvalidIP)
+
+		_, err = ParseRecord("10.0.0.1 bad..lan")
+		assert.ErrorIs(t, err, ErrInvalidDomain)
+	})
+}
+
+func TestValidateRecords(t *testing.T) {
+	t.Run("no error on valid records", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		err := ValidateRecords([]DNSRecord{
+			{Domain: "test.lan", IP: "127.0.0.1"},
+			{Domain: "_srv.my-host.lan", IP: "::1"},
+		})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("validate the canonical domain like create", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		err := ValidateRecords([]DNSRecord{
+			{Domain: "Host.lan.", IP: "127.0.0.1"},
+			{Domain: "bücher.lan", IP: "127.0.0.1"},
+		})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("error naming every invalid record", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		err := ValidateRecords([]DNSRecord{
+			{Domain: "test.lan", IP: "127.0.0.1"},
+			{Domain: "", IP: "127.0.0.1"},
+			{Domain: "bad..lan", IP: "not-an-ip"},
+		})
+
+		assert.ErrorIs(t, err, ErrInvalidDomain)
+		assert.ErrorIs(t, err, ErrInvalidIP)
+		assert.Contains(t, err.Error(), "record 1:")
+		assert.Contains(t, err.Error(), "record 2:")
+		assert.NotContains(t, err.Error(), "record 0:")
+	})
+}
+
+func TestLocalDNSCreateReadback(t *testing.T) {
+	t.Run("return unverified record when readback exceeds deadline", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("action") == "add" {
+				fmt.Fprint(w, `{"success":true,"message":""}`)
+				return
+			}
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		record, err := c.LocalDNS.Create(ctx, "test.lan", "127.0.0.1")
+		assert.ErrorIs(t, err, ErrLocalDNSUnverified)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, &DNSRecord{Domain: "test.lan", IP: "127.0.0.1"}, record)
+	})
+
+	t.Run("return matching record from readback", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("action") == "add" {
+				fmt.Fprint(w, `{"success":true,"message":""}`)
+				return
+			}
+			fmt.Fprint(w, `{"data":[["test.lan","127.0.0.1"],["test.lan","127.0.0.2"],["other.lan","127.0.0.3"]]}`)
+		})
+
+		record, err := c.LocalDNS.Create(context.Background(), "test.lan", "127.0.0.1")
+		require.NoError(t, err)
+		assert.Equal(t, &DNSRecord{Domain: "test.lan", IP: "127.0.0.1"}, record)
+	})
+}
+
+func TestLocalDNSNormalize(t *testing.T) {
+	t.Run("create stores the canonical domain", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		record, err := c.LocalDNS.Create(context.Background(), "MyHost.Bücher.LAN", "127.0.0.1")
+		require.NoError(t, err)
+
+		assert.Equal(t, "myhost.xn--bcher-kva.lan", record.Domain)
+		assert.Equal(t, DNSRecordList{{Domain: "myhost.xn--bcher-kva.lan", IP: "127.0.0.1"}}, fake.list())
+
+		_, err = c.LocalDNS.Get(context.Background(), "myhost.bücher.lan")
+		assert.NoError(t, err)
+	})
+
+	t.Run("create strips a trailing dot", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		record, err := c.LocalDNS.Create(context.Background(), "host.lan.", "127.0.0.1")
+		require.NoError(t, err)
+
+		assert.Equal(t, &DNSRecord{Domain: "host.lan", IP: "127.0.0.1"}, record)
+		assert.Equal(t, DNSRecordList{{Domain: "host.lan", IP: "127.0.0.1"}}, fake.list())
+	})
+
+	t.Run("find and delete records stored with a trailing dot", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(DNSRecord{Domain: "host.lan.", IP: "127.0.0.1"})
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		record, err := c.LocalDNS.Get(context.Background(), "host.lan")
+		require.NoError(t, err)
+		assert.Equal(t, &DNSRecord{Domain: "host.lan.", IP: "127.0.0.1"}, record)
+
+		require.NoError(t, c.LocalDNS.Delete(context.Background(), "host.lan"))
+		assert.Empty(t, fake.list())
+	})
+
+	t.Run("detect and rewrite trailing dot records", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(
+			DNSRecord{Domain: "a.lan.", IP: "127.0.0.1"},
+			DNSRecord{Domain: "b.lan", IP: "127.0.0.2"},
+		)
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		list, err := c.LocalDNS.DetectTrailingDotRecords(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, DNSRecordList{{Domain: "a.lan.", IP: "127.0.0.1"}}, list)
+
+		fixed, err := c.LocalDNS.NormalizeExisting(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, fixed)
+		assert.ElementsMatch(t, DNSRecordList{
+			{Domain: "a.lan", IP: "127.0.0.1"},
+			{Domain: "b.lan", IP: "127.0.0.2"},
+		}, fake.list())
+	})
+
+	t.Run("rewrite mixed-case records", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(
+			DNSRecord{Domain: "MyHost.LAN", IP: "127.0.0.1"},
+			DNSRecord{Domain: "ok.lan", IP: "127.0.0.2"},
+			DNSRecord{Domain: "dup.lan", IP: "127.0.0.3"},
+			DNSRecord{Domain: "DUP.lan", IP: "127.0.0.3"},
+		)
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		fixed, err := c.LocalDNS.NormalizeExisting(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, fixed)
+		assert.ElementsMatch(t, DNSRecordList{
+			{Domain: "ok.lan", IP: "127.0.0.2"},
+			{Domain: "dup.lan", IP: "127.0.0.3"},
+			{Domain: "myhost.lan", IP: "127.0.0.1"},
+		}, fake.list())
+	})
+}
+
+func TestLocalDNSDomains(t *testing.T) {
+	t.Run("return sorted unique domains", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(
+			DNSRecord{Domain: "b.lan", IP: "127.0.0.1"},
+			DNSRecord{Domain: "a.lan", IP: "127.0.0.2"},
+			DNSRecord{Domain: "b.lan", IP: "::1"},
+		)
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		domains, err := c.LocalDNS.Domains(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"a.lan", "b.lan"}, domains)
+	})
+}
+
+func TestLocalDNSExistsMany(t *testing.T) {
+	t.Run("report presence per domain with one list", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(
+			DNSRecord{Domain: "a.lan", IP: "10.0.0.1"},
+			DNSRecord{Domain: "b.lan", IP: "10.0.0.2"},
+		)
+
+		var requests int32
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			fake.ServeHTTP(w, r)
+		})
+
+		exists, err := c.LocalDNS.ExistsMany(context.Background(), []string{"a.lan", "B.lan", "c.lan"})
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]bool{"a.lan": true, "B.lan": true, "c.lan": false}, exists)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("find records stored with a trailing dot", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, newFakeLocalDNS(DNSRecord{Domain: "host.lan.", IP: "10.0.0.1"}).ServeHTTP)
+
+		exists, err := c.LocalDNS.ExistsMany(context.Background(), []string{"host.lan"})
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]bool{"host.lan": true}, exists)
+	})
+}
+
+func TestLocalDNSCreateFromDevice(t *testing.T) {
+	t.Run("create record from sanitized hostname", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		record, err := c.LocalDNS.CreateFromDevice(context.Background(), NetworkDevice{
+			IPs:   []string{"10.0.0.4", "10.0.0.5"},
+			Names: []string{"*", "John's Laptop.fritz.box"},
+		}, "home.lan.")
+		require.NoError(t, err)
+
+		assert.Equal(t, &DNSRecord{Domain: "johnslaptop.home.lan", IP: "10.0.0.5"}, record)
+	})
+
+	t.Run("error on device without hostname", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		_, err := c.LocalDNS.CreateFromDevice(context.Background(), NetworkDevice{
+			HWAddr: "aa:bb:cc:dd:ee:ff",
+			IPs:    []string{"10.0.0.4"},
+			Names:  []string{""},
+		}, "home.lan")
+
+		assert.ErrorIs(t, err, ErrNoUsableHostname)
+		assert.Empty(t, fake.list())
+	})
+}
+
+func TestLocalDNSNotFound(t *testing.T) {
+	t.Run("wrap the shared not found error", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, newFakeLocalDNS().ServeHTTP)
+
+		_, err := c.LocalDNS.Get(context.Background(), "missing.lan")
+
+		assert.ErrorIs(t, err, ErrorLocalDNSNotFound)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.NotErrorIs(t, err, ErrorLocalCNAMENotFound)
+		assert.Equal(t, "local dns record not found: missing.lan", err.Error())
+	})
+}
+
+func TestLocalDNSListFunc(t *testing.T) {
+	t.Run("return only kept records", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(
+			DNSRecord{Domain: "a.lan", IP: "10.0.0.1"},
+			DNSRecord{Domain: "b.lan", IP: "10.0.1.1"},
+			DNSRecord{Domain: "c.lan", IP: "10.0.0.2"},
+		)
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		list, err := c.LocalDNS.ListFunc(context.Background(), func(record DNSRecord) bool {
+			return strings.HasPrefix(record.IP, "10.0.0.")
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, DNSRecordList{
+			{Domain: "a.lan", IP: "10.0.0.1"},
+			{Domain: "c.lan", IP: "10.0.0.2"},
+		}, list)
+	})
+}
+
+func TestLocalDNSCreateValidation(t *testing.T) {
+	t.Run("reject an invalid domain without a request", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		_, err := c.LocalDNS.Create(context.Background(), "", "10.0.0.1")
+		assert.ErrorIs(t, err, ErrInvalidDomain)
+
+		_, err = c.LocalDNS.Create(context.Background(), "bad..lan", "10.0.0.1")
+		assert.ErrorIs(t, err, ErrInvalidDomain)
+
+		assert.Empty(t, fake.list())
+	})
+
+	t.Run("reject an invalid IP without a request", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		_, err := c.LocalDNS.Create(context.Background(), "a.lan", "")
+		assert.ErrorIs(t, err, ErrInvalidIP)
+
+		_, err = c.LocalDNS.Create(context.Background(), "a.lan", "10.0.0")
+		assert.ErrorIs(t, err, ErrInvalidIP)
+
+		assert.Empty(t, fake.list())
+	})
+}
+
+func TestLocalDNSSwapIP(t *testing.T) {
+	t.Run("add the new IP before removing the old one", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(DNSRecord{Domain: "app.lan", IP: "10.0.0.1"})
+
+		var actions []string
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			if action := r.URL.Query().Get("action"); action != "get" {
+				actions = append(actions, action+" "+r.URL.Query().Get("ip"))
+			}
+			fake.ServeHTTP(w, r)
+		})
+
+		err := c.LocalDNS.SwapIP(context.Background(), "App.lan", "10.0.0.1", "10.0.0.2")
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"add 10.0.0.2", "delete 10.0.0.1"}, actions)
+		assert.Equal(t, DNSRecordList{{Domain: "app.lan", IP: "10.0.0.2"}}, fake.list())
+	})
+
+	t.Run("error without changes when the old record is missing", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(DNSRecord{Domain: "app.lan", IP: "10.0.0.3"})
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		err := c.LocalDNS.SwapIP(context.Background(), "app.lan", "10.0.0.1", "10.0.0.2")
+		assert.ErrorIs(t, err, ErrorLocalDNSNotFound)
+
+		err = c.LocalDNS.SwapIP(context.Background(), "other.lan", "10.0.0.1", "10.0.0.2")
+		assert.ErrorIs(t, err, ErrorLocalDNSNotFound)
+
+		assert.Equal(t, DNSRecordList{{Domain: "app.lan", IP: "10.0.0.3"}}, fake.list())
+	})
+}
+
+func TestLocalDNSListOrder(t *testing.T) {
+	records := []DNSRecord{
+		{Domain: "c.lan", IP: "10.0.0.3"},
+		{Domain: "a.lan", IP: "10.0.0.2"},
+		{Domain: "b.lan", IP: "10.0.0.1"},
+		{Domain: "a.lan", IP: "10.0.0.0"},
+	}
+
+	t.Run("keep the server order", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, newFakeLocalDNS(records...).ServeHTTP)
+
+		list, err := c.LocalDNS.List(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, DNSRecordList(records), list)
+
+		stable, err := c.LocalDNS.ListStable(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, DNSRecordList(records), stable)
+	})
+
+	t.Run("keep the server order from the cache", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{CacheTTL: time.Minute}, newFakeLocalDNS(records...).ServeHTTP)
+
+		for i := 0; i < 2; i++ {
+			stable, err := c.LocalDNS.ListStable(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, DNSRecordList(records), stable)
+		}
+	})
+}
+
+func TestLocalDNSListUnique(t *testing.T) {
+	t.Run("drop identical duplicates", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(
+			DNSRecord{Domain: "a.lan", IP: "10.0.0.1"},
+			DNSRecord{Domain: "b.lan", IP: "10.0.0.2"},
+			DNSRecord{Domain: "a.lan", IP: "10.0.0.1"},
+			DNSRecord{Domain: "a.lan", IP: "10.0.0.3"},
+		)
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		list, err := c.LocalDNS.List(context.Background())
+		require.NoError(t, err)
+		assert.Len(t, list, 4)
+
+		unique, err := c.LocalDNS.ListUnique(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, DNSRecordList{
+			{Domain: "a.lan", IP: "10.0.0.1"},
+			{Domain: "b.lan", IP: "10.0.0.2"},
+			{Domain: "a.lan", IP: "10.0.0.3"},
+		}, unique)
+	})
+}
+
+func TestLocalDNSListByFamily(t *testing.T) {
+	t.Run("split records by address family", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(
+			DNSRecord{Domain: "a.lan", IP: "10.0.0.1"},
+			DNSRecord{Domain: "a.lan", IP: "fd00::1"},
+			DNSRecord{Domain: "b.lan", IP: "::ffff:10.0.0.2"},
+			DNSRecord{Domain: "c.lan", IP: "fe80::1%eth0"},
+			DNSRecord{Domain: "d.lan", IP: "bogus"},
+		)
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		v4, v6, err := c.LocalDNS.ListByFamily(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, DNSRecordList{
+			{Domain: "a.lan", IP: "10.0.0.1"},
+			{Domain: "b.lan", IP: "::ffff:10.0.0.2"},
+		}, v4)
+		assert.Equal(t, DNSRecordList{
+			{Domain: "a.lan", IP: "fd00::1"},
+			{Domain: "c.lan", IP: "fe80::1%eth0"},
+		}, v6)
+	})
+}
+
+func TestLocalDNSListRaw(t *testing.T) {
+	t.Run("return the body with the parsed records", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(DNSRecord{Domain: "a.lan", IP: "10.0.0.1"})
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		raw, list, err := c.LocalDNS.ListRaw(context.Background())
+		require.NoError(t, err)
+
+		assert.JSONEq(t, `{"data":[["a.lan","10.0.0.1"]]}`, string(raw))
+		assert.Equal(t, DNSRecordList{{Domain: "a.lan", IP: "10.0.0.1"}}, list)
+	})
+
+	t.Run("return the body when it cannot be parsed", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"data":"unexpected"}`)
+		})
+
+		raw, list, err := c.LocalDNS.ListRaw(context.Background())
+		require.Error(t, err)
+
+		assert.JSONEq(t, `{"data":"unexpected"}`, string(raw))
+		assert.Nil(t, list)
+	})
+}
+
+func TestLocalDNSGetByCIDR(t *testing.T) {
+	fake := newFakeLocalDNS(
+		DNSRecord{Domain: "a.lan", IP: "10.0.5.1"},
+		DNSRecord{Domain: "b.lan", IP: "10.0.6.1"},
+		DNSRecord{Domain: "c.lan", IP: "fd00::1"},
+		DNSRecord{Domain: "d.lan", IP: "10.0.5.254"},
+	)
+
+	t.Run("return records within the subnet", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		list, err := c.LocalDNS.GetByCIDR(context.Background(), "10.0.5.0/24")
+		require.NoError(t, err)
+
+		assert.Equal(t, DNSRecordList{
+			{Domain: "a.lan", IP: "10.0.5.1"},
+			{Domain: "d.lan", IP: "10.0.5.254"},
+		}, list)
+	})
+
+	t.Run("return IPv6 records within the subnet", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		list, err := c.LocalDNS.GetByCIDR(context.Background(), "fd00::/8")
+		require.NoError(t, err)
+
+		assert.Equal(t, DNSRecordList{{Domain: "c.lan", IP: "fd00::1"}}, list)
+	})
+
+	t.Run("error on invalid CIDR", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		_, err := c.LocalDNS.GetByCIDR(context.Background(), "10.0.5.0")
+		assert.Error(t, err)
+	})
+}
+
+func TestIPHandling(t *testing.T) {
+	t.Run("accept IPv6 zone index", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		assert.NoError(t, validateIP("fe80::1%eth0"))
+		assert.NoError(t, validateIP("fe80::1"))
+		assert.ErrorIs(t, validateIP("10.0.0.1%eth0"), ErrInvalidIP)
+		assert.ErrorIs(t, validateIP("fe80::1%"), ErrInvalidIP)
+	})
+
+	t.Run("compare addresses by value", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		assert.True(t, sameIP("fe80:0:0::1", "fe80::1"))
+		assert.True(t, sameIP("fe80::1%eth0", "FE80::1%eth0"))
+		assert.True(t, sameIP("::ffff:10.0.0.1", "10.0.0.1"))
+		assert.False(t, sameIP("fe80::1%eth0", "fe80::1"))
+		assert.False(t, sameIP("fe80::1%eth0", "fe80::1%eth1"))
+	})
+
+	t.Run("match zoned link-local records by subnet", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(DNSRecord{Domain: "router.lan", IP: "fe80::1%eth0"})
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		list, err := c.LocalDNS.GetByCIDR(context.Background(), "fe80::/10")
+		require.NoError(t, err)
+
+		assert.Equal(t, DNSRecordList{{Domain: "router.lan", IP: "fe80::1%eth0"}}, list)
+	})
+
+	t.Run("read back records stored in another textual form", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("action") == "add" {
+				fmt.Fprint(w, `{"success":true,"message":""}`)
+				return
+			}
+			fmt.Fprint(w, `{"data":[["test.lan","fd00::1"]]}`)
+		})
+
+		record, err := c.LocalDNS.Create(context.Background(), "test.lan", "fd00:0::1")
+		require.NoError(t, err)
+
+		assert.Equal(t, "fd00::1", record.IP)
+	})
+}
+
+func TestLocalDNSGetList(t *testing.T) {
+	fake := newFakeLocalDNS(
+		DNSRecord{Domain: "a.lan", IP: "10.0.0.1"},
+		DNSRecord{Domain: "b.lan", IP: "10.0.0.2"},
+		DNSRecord{Domain: "c.lan", IP: "10.0.0.1"},
+	)
+
+	t.Run("match on domain", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		list, err := c.LocalDNS.GetList(context.Background(), "B.lan")
+		require.NoError(t, err)
+
+		assert.Equal(t, []*DNSRecord{{Domain: "b.lan", IP: "10.0.0.2"}}, list)
+	})
+
+	t.Run("match on IP when given an address", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		list, err := c.LocalDNS.GetList(context.Background(), "10.0.0.1")
+		require.NoError(t, err)
+
+		assert.Equal(t, []*DNSRecord{
+			{Domain: "a.lan", IP: "10.0.0.1"},
+			{Domain: "c.lan", IP: "10.0.0.1"},
+		}, list)
+	})
+
+	t.Run("delete never matches on IP", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(DNSRecord{Domain: "a.lan", IP: "10.0.0.1"})
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		err := c.LocalDNS.Delete(context.Background(), "10.0.0.1")
+		require.NoError(t, err)
+
+		assert.Equal(t, DNSRecordList{{Domain: "a.lan", IP: "10.0.0.1"}}, fake.list())
+	})
+}
+
+func TestLocalDNSShareListRequests(t *testing.T) {
+	// newBlockingClient holds every list request until release is closed, signalling
+	// arrived when the first one reaches the server
+	newBlockingClient := func(t *testing.T, gets *int32) (c *Client, arrived chan struct{}, release chan struct{}) {
+		fake := newFakeLocalDNS(DNSRecord{Domain: "a.lan", IP: "10.0.0.1"})
+		arrived, release = make(chan struct{}), make(chan struct{})
+
+		c = newUnitTestClient(t, Config{ShareListRequests: true}, func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(gets, 1) == 1 {
+				close(arrived)
+			}
+			<-release
+			fake.ServeHTTP(w, r)
+		})
+
+		return c, arrived, release
+	}
+
+	t.Run("share one request between concurrent lists", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		var gets int32
+		c, arrived, release := newBlockingClient(t, &gets)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				list, err := c.LocalDNS.List(context.Background())
+				assert.NoError(t, err)
+				assert.Equal(t, DNSRecordList{{Domain: "a.lan", IP: "10.0.0.1"}}, list)
+			}()
+		}
+
+		<-arrived
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&gets))
+	})
+
+	t.Run("do not join a request started before a mutation", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+		arrived, release := make(chan struct{}), make(chan struct{})
+		var gets int32
+		c := newUnitTestClient(t, Config{ShareListRequests: true, CacheTTL: time.Minute}, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("action") == "get" && atomic.AddInt32(&gets, 1) == 1 {
+				// answer with the records as they were before the create
+				close(arrived)
+				<-release
+				fmt.Fprint(w, `{"data":[]}`)
+				return
+			}
+			fake.ServeHTTP(w, r)
+		})
+		ctx := context.Background()
+
+		var once sync.Once
+		releaseHeld := func() { once.Do(func() { close(release) }) }
+		defer releaseHeld()
+
+		stale := make(chan DNSRecordList, 1)
+		go func() {
+			list, err := c.LocalDNS.List(ctx)
+			assert.NoError(t, err)
+			stale <- list
+		}()
+		<-arrived
+
+		_, err := c.LocalDNS.Create(ctx, "new.lan", "10.0.0.1")
+		require.NoError(t, err)
+
+		// joining the held request would wait until release and time out
+		listCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		list, err := c.LocalDNS.List(listCtx)
+		require.NoError(t, err)
+		assert.Equal(t, DNSRecordList{{Domain: "new.lan", IP: "10.0.0.1"}}, list)
+
+		releaseHeld()
+		assert.Empty(t, <-stale)
+
+		list, err = c.LocalDNS.List(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, DNSRecordList{{Domain: "new.lan", IP: "10.0.0.1"}}, list)
+	})
+
+	t.Run("keep the shared request when one caller cancels", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		var gets int32
+		c, arrived, release := newBlockingClient(t, &gets)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancelled := make(chan error)
+		go func() {
+			_, err := c.LocalDNS.List(ctx)
+			cancelled <- err
+		}()
+
+		<-arrived
+		done := make(chan error)
+		go func() {
+			_, err := c.LocalDNS.List(context.Background())
+			done <- err
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		assert.ErrorIs(t, <-cancelled, context.Canceled)
+
+		close(release)
+		assert.NoError(t, <-done)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&gets))
+	})
+}
+
+func TestLocalDNSGetByRegexp(t *testing.T) {
+	fake := newFakeLocalDNS(
+		DNSRecord{Domain: "web-01.prod.lan", IP: "10.0.0.1"},
+		DNSRecord{Domain: "DB-01.prod.lan", IP: "10.0.0.2"},
+		DNSRecord{Domain: "web-01.dev.lan", IP: "10.0.1.1"},
+	)
+
+	t.Run("return records matching the lowercased domain", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		list, err := c.LocalDNS.GetByRegexp(context.Background(), `^(web|db)-\d+\.prod\.lan$`)
+		require.NoError(t, err)
+
+		assert.Equal(t, DNSRecordList{
+			{Domain: "web-01.prod.lan", IP: "10.0.0.1"},
+			{Domain: "DB-01.prod.lan", IP: "10.0.0.2"},
+		}, list)
+	})
+
+	t.Run("error on invalid pattern without a request", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			t.Error("unexpected request")
+		})
+
+		_, err := c.LocalDNS.GetByRegexp(context.Background(), `web-(`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid domain pattern")
+	})
+}
@@ -0,0 +1,94 @@
+package pihole
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+)
+
+type Network interface {
+	// List returns the devices seen on the network
+	List(ctx context.Context) ([]NetworkDevice, error)
+}
+
+type network struct {
+	client *Client
+}
+
+// NetworkDevice is a device from Pi-hole's network table
+type NetworkDevice struct {
+	ID         int
+	HWAddr     string
+	Interface  string
+	MACVendor  string
+	FirstSeen  time.Time
+	LastQuery  time.Time
+	NumQueries int
+
+	// IPs and Names are parallel: Names[i] is the host name last seen for IPs[i], or empty
+	IPs   []string
+	Names []string
+}
+
+type networkResponse struct {
+	Network []networkDeviceResponse `json:"network"`
+}
+
+type networkDeviceResponse struct {
+	ID         int      `json:"id"`
+	HWAddr     string   `json:"hwaddr"`
+	Interface  string   `json:"interface"`
+	MACVendor  string   `json:"macVendor"`
+	FirstSeen  int64    `json:"firstSeen"`
+	LastQuery  int64    `json:"lastQuery"`
+	NumQueries int      `json:"numQueries"`
+	IP         []string `json:"ip"`
+	Name       []string `json:"name"`
+}
+
+func (res networkDeviceResponse) toNetworkDevice() NetworkDevice {
+	return NetworkDevice{
+		ID:         res.ID,
+		HWAddr:     res.HWAddr,
+		Interface:  res.Interface,
+		MACVendor:  res.MACVendor,
+		FirstSeen:  epochTime(res.FirstSeen),
+		LastQuery:  epochTime(res.LastQuery),
+		NumQueries: res.NumQueries,
+		IPs:        res.IP,
+		Names:      res.Name,
+	}
+}
+
+// List returns the devices in Pi-hole's network table
+func (n network) List(ctx context.Context) ([]NetworkDevice, error) {
+	req, err := n.client.request(ctx, "api_db.php", url.Values{
+		"network": []string{"true"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := n.client.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer drainAndClose(res.Body)
+
+	var netRes *networkResponse
+	if err := n.client.decode(res.Body, &netRes); err != nil {
+		return nil, decodeError("network table", err)
+	}
+	if netRes == nil {
+		return nil, errors.New("failed to parse network table body: missing network")
+	}
+
+	devices := make([]NetworkDevice, len(netRes.Network))
+	for i, device := range netRes.Network {
+		devices[i] = device.toNetworkDevice()
+	}
+
+	return devices, nil
+}
@@ -0,0 +1,60 @@
+package pihole
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetwork(t *testing.T) {
+	t.Run("parse network table", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/admin/api_db.php", r.URL.Path)
+			fmt.Fprint(w, `{"network":[{"id":1,"hwaddr":"aa:bb:cc:dd:ee:ff","interface":"eth0","firstSeen":1650000000,"lastQuery":1650003600,"numQueries":42,"macVendor":"Acme","aliasclient_id":null,"ip":["10.0.0.5","fe80::1"],"name":["laptop",""]}]}`)
+		})
+
+		devices, err := c.Network.List(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, []NetworkDevice{{
+			ID:         1,
+			HWAddr:     "aa:bb:cc:dd:ee:ff",
+			Interface:  "eth0",
+			MACVendor:  "Acme",
+			FirstSeen:  time.Unix(1650000000, 0).UTC(),
+			LastQuery:  time.Unix(1650003600, 0).UTC(),
+			NumQueries: 42,
+			IPs:        []string{"10.0.0.5", "fe80::1"},
+			Names:      []string{"laptop", ""},
+		}}, devices)
+	})
+
+	t.Run("error on null body", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `null`)
+		})
+
+		_, err := c.Network.List(context.Background())
+		assert.Error(t, err)
+	})
+
+	t.Run("list network devices", func(t *testing.T) {
+		isAcceptance(t)
+
+		c := newTestClient(t)
+
+		_, err := c.Network.List(context.Background())
+		require.NoError(t, err)
+	})
+}
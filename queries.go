@@ -0,0 +1,223 @@
+package pihole
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Queries interface {
+	// Search returns the queries of the long-term database matching filter
+	Search(ctx context.Context, filter QueryFilter) ([]Query, error)
+}
+
+type queries struct {
+	client *Client
+}
+
+// QueryStatus is how Pi-hole answered a query
+type QueryStatus int
+
+const (
+	QueryStatusBlockedGravity QueryStatus = 1
+	QueryStatusForwarded      QueryStatus = 2
+	QueryStatusCached         QueryStatus = 3
+	QueryStatusBlockedRegex   QueryStatus = 4
+	QueryStatusBlockedExact   QueryStatus = 5
+
+	// The upstream server answered with a blocking IP, the null address or NXDOMAIN
+	QueryStatusBlockedUpstreamIP       QueryStatus = 6
+	QueryStatusBlockedUpstreamNull     QueryStatus = 7
+	QueryStatusBlockedUpstreamNXDOMAIN QueryStatus = 8
+
+	// A CNAME in the answer was blocked by gravity, a regex or the exact deny list
+	QueryStatusBlockedGravityCNAME QueryStatus = 9
+	QueryStatusBlockedRegexCNAME   QueryStatus = 10
+	QueryStatusBlockedExactCNAME   QueryStatus = 11
+
+	// FTL blocked the query because the gravity database was busy, or because the domain
+	// is a special one such as Mozilla's canary domain
+	QueryStatusBlockedDatabaseBusy  QueryStatus = 15
+	QueryStatusBlockedSpecialDomain QueryStatus = 16
+)
+
+// Blocked reports whether the status is one of the ways Pi-hole blocks a query: by gravity,
+// regex or exact deny list, directly or via a CNAME, by the upstream server, while the
+// database was busy or for a special domain
+func (status QueryStatus) Blocked() bool {
+	switch status {
+	case QueryStatusBlockedGravity, QueryStatusBlockedRegex, QueryStatusBlockedExact,
+		QueryStatusBlockedUpstreamIP, QueryStatusBlockedUpstreamNull, QueryStatusBlockedUpstreamNXDOMAIN,
+		QueryStatusBlockedGravityCNAME, QueryStatusBlockedRegexCNAME, QueryStatusBlockedExactCNAME,
+		QueryStatusBlockedDatabaseBusy, QueryStatusBlockedSpecialDomain:
+		return true
+	}
+
+	return false
+}
+
+// QueryFilter narrows a query search. Zero fields do not filter.
+type QueryFilter struct {
+	Domain string
+
+	// Client is the client IP or host name
+	Client string
+
+	// Statuses keeps only queries answered with one of these statuses
+	Statuses []QueryStatus
+
+	// From defaults to DefaultQueryWindow before To, and To to now
+	From time.Time
+	To   time.Time
+}
+
+// DefaultQueryWindow is how far back Search looks when QueryFilter.From is unset. Every
+// matching row is downloaded, so a wider window can exceed Config.MaxResponseBytes on a busy
+// server and fail with ErrResponseTooLarge.
+const DefaultQueryWindow = 24 * time.Hour
+
+// Query is a single entry of the long-term query database
+type Query struct {
+	Time   time.Time
+	Type   string
+	Domain string
+	Client string
+	Status QueryStatus
+}
+
+type queriesResponse struct {
+	Data [][]json.RawMessage `json:"data"`
+}
+
+// Search returns the queries of the long-term database matching filter, oldest first. The
+// filter is sent to Pi-hole so only matching rows cross the wire; it is also applied to the
+// response, because some Pi-hole versions honour only one of the domain and client filters.
+// Every matching row is downloaded, so keep the window small enough for MaxResponseBytes.
+func (q queries) Search(ctx context.Context, filter QueryFilter) ([]Query, error) {
+	to := filter.To
+	if to.IsZero() {
+		to = q.client.now()
+	}
+
+	from := filter.From
+	if from.IsZero() {
+		from = to.Add(-DefaultQueryWindow)
+	}
+
+	vals := url.Values{
+		"getAllQueries": []string{"true"},
+		"from":          []string{strconv.FormatInt(from.Unix(), 10)},
+		"until":         []string{strconv.FormatInt(to.Unix(), 10)},
+	}
+	if filter.Domain != "" {
+		vals.Set("domain", filter.Domain)
+	}
+	if filter.Client != "" {
+		vals.Set("client", filter.Client)
+	}
+	if len(filter.Statuses) > 0 {
+		types := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			types[i] = strconv.Itoa(int(status))
+		}
+		vals.Set("types", strings.Join(types, ","))
+	}
+
+	req, err := q.client.request(ctx, "api_db.php", vals)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := q.client.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer drainAndClose(res.Body)
+
+	var queriesRes queriesResponse
+	if err := q.client.decode(res.Body, &queriesRes); err != nil {
+		return nil, decodeError("queries", err)
+	}
+
+	results := []Query{}
+	for i, row := range queriesRes.Data {
+		query, err := parseQueryRow(row)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse query %d: %w", i, err)
+		}
+		if filter.matches(query) {
+			results = append(results, query)
+		}
+	}
+
+	return results, nil
+}
+
+// matches reports whether query passes the domain, client and status filters
+func (filter QueryFilter) matches(query Query) bool {
+	if filter.Domain != "" && !strings.EqualFold(query.Domain, filter.Domain) {
+		return false
+	}
+	if filter.Client != "" && !strings.EqualFold(query.Client, filter.Client) {
+		return false
+	}
+	if len(filter.Statuses) == 0 {
+		return true
+	}
+
+	for _, status := range filter.Statuses {
+		if query.Status == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseQueryRow reads a [timestamp, type, domain, client, status, ...] row. Pi-hole sends
+// the numbers as JSON numbers or strings depending on the version.
+func parseQueryRow(row []json.RawMessage) (Query, error) {
+	if len(row) < 5 {
+		return Query{}, fmt.Errorf("expected at least 5 fields, got %d", len(row))
+	}
+
+	timestamp, err := parseLooseInt(row[0])
+	if err != nil {
+		return Query{}, fmt.Errorf("timestamp: %w", err)
+	}
+
+	status, err := parseLooseInt(row[4])
+	if err != nil {
+		return Query{}, fmt.Errorf("status: %w", err)
+	}
+
+	query := Query{Time: epochTime(timestamp), Status: QueryStatus(status)}
+	if err := json.Unmarshal(row[1], &query.Type); err != nil {
+		return Query{}, fmt.Errorf("type: %w", err)
+	}
+	if err := json.Unmarshal(row[2], &query.Domain); err != nil {
+		return Query{}, fmt.Errorf("domain: %w", err)
+	}
+	if err := json.Unmarshal(row[3], &query.Client); err != nil {
+		return Query{}, fmt.Errorf("client: %w", err)
+	}
+
+	return query, nil
+}
+
+// parseLooseInt decodes an integer sent either as a JSON number or as a string
+func parseLooseInt(raw json.RawMessage) (int64, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return strconv.ParseInt(s, 10, 64)
+	}
+
+	var n int64
+	err := json.Unmarshal(raw, &n)
+	return n, err
+}
@@ -0,0 +1,103 @@
+package pihole
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueriesSearch(t *testing.T) {
+	t.Run("send the filter and parse matching queries", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		from := time.Unix(1650000000, 0)
+		to := from.Add(7 * 24 * time.Hour)
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+			assert.Equal(t, "/admin/api_db.php", r.URL.Path)
+			assert.True(t, q.Has("getAllQueries"))
+			assert.Equal(t, "1650000000", q.Get("from"))
+			assert.Equal(t, fmt.Sprint(to.Unix()), q.Get("until"))
+			assert.Equal(t, "doubleclick.net", q.Get("domain"))
+			assert.Equal(t, "10.0.0.5", q.Get("client"))
+			assert.Equal(t, "1,4,5", q.Get("types"))
+
+			// The client filter is ignored here like on servers honouring only the domain
+			fmt.Fprint(w, `{"data":[`+
+				`[1650000100,"A","doubleclick.net","10.0.0.5",1,0],`+
+				`["1650000200","AAAA","doubleclick.net","10.0.0.6","5",0]]}`)
+		})
+
+		results, err := c.Queries.Search(context.Background(), QueryFilter{
+			Domain:   "doubleclick.net",
+			Client:   "10.0.0.5",
+			Statuses: []QueryStatus{QueryStatusBlockedGravity, QueryStatusBlockedRegex, QueryStatusBlockedExact},
+			From:     from,
+			To:       to,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []Query{{
+			Time:   time.Unix(1650000100, 0).UTC(),
+			Type:   "A",
+			Domain: "doubleclick.net",
+			Client: "10.0.0.5",
+			Status: QueryStatusBlockedGravity,
+		}}, results)
+	})
+
+	t.Run("search the default window", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		now := time.Date(2022, 4, 15, 12, 0, 0, 0, time.UTC)
+		c := newUnitTestClient(t, Config{Clock: &fakeClock{now: now}}, func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+			assert.Equal(t, fmt.Sprint(now.Add(-DefaultQueryWindow).Unix()), q.Get("from"))
+			assert.Equal(t, fmt.Sprint(now.Unix()), q.Get("until"))
+			assert.False(t, q.Has("domain"))
+			assert.False(t, q.Has("types"))
+			fmt.Fprint(w, `{"data":[]}`)
+		})
+
+		results, err := c.Queries.Search(context.Background(), QueryFilter{})
+		require.NoError(t, err)
+		assert.Empty(t, results)
+	})
+
+	t.Run("error on malformed rows", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"data":[[1650000100,"A"]]}`)
+		})
+
+		_, err := c.Queries.Search(context.Background(), QueryFilter{})
+		assert.Error(t, err)
+	})
+}
+
+func TestQueryStatusBlocked(t *testing.T) {
+	t.Run("classify blocking statuses", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		assert.True(t, QueryStatusBlockedGravity.Blocked())
+		assert.True(t, QueryStatusBlockedRegex.Blocked())
+		assert.True(t, QueryStatusBlockedExact.Blocked())
+		assert.True(t, QueryStatusBlockedUpstreamNXDOMAIN.Blocked())
+		assert.True(t, QueryStatusBlockedGravityCNAME.Blocked())
+		assert.True(t, QueryStatusBlockedSpecialDomain.Blocked())
+		assert.False(t, QueryStatus(12).Blocked())
+		assert.False(t, QueryStatusForwarded.Blocked())
+		assert.False(t, QueryStatusCached.Blocked())
+	})
+}
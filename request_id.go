@@ -0,0 +1,19 @@
+package pihole
+
+import "context"
+
+// requestIDKey is the context key under which WithRequestID stores a correlation ID
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying a correlation ID. The client copies it into
+// the AuditEvent of every mutation made with the context and, when Config.RequestIDHeader
+// is set, sends it in that header.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the correlation ID stored by WithRequestID, if any
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok && id != ""
+}
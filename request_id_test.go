@@ -0,0 +1,71 @@
+package pihole
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	t.Run("send the context ID in the configured header", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+
+		var mu sync.Mutex
+		var ids []string
+		c := newUnitTestClient(t, Config{RequestIDHeader: "X-Request-ID"}, func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			ids = append(ids, r.Header.Get("X-Request-ID"))
+			mu.Unlock()
+			fake.ServeHTTP(w, r)
+		})
+
+		_, err := c.LocalDNS.List(WithRequestID(context.Background(), "req-1"))
+		require.NoError(t, err)
+
+		_, err = c.LocalDNS.List(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"req-1", ""}, ids)
+	})
+
+	t.Run("omit the header unless configured", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+
+		var header http.Header
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header.Clone()
+			fake.ServeHTTP(w, r)
+		})
+
+		_, err := c.LocalDNS.List(WithRequestID(context.Background(), "req-1"))
+		require.NoError(t, err)
+
+		assert.Empty(t, header.Get("X-Request-ID"))
+	})
+
+	t.Run("attach the context ID to audit events", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		var events []AuditEvent
+		c := newUnitTestClient(t, Config{
+			AuditFunc: func(event AuditEvent) { events = append(events, event) },
+		}, newFakeLocalDNS().ServeHTTP)
+
+		_, err := c.LocalDNS.Create(WithRequestID(context.Background(), "req-2"), "a.lan", "10.0.0.1")
+		require.NoError(t, err)
+
+		require.Len(t, events, 1)
+		assert.Equal(t, "req-2", events[0].RequestID)
+	})
+}
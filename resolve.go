@@ -0,0 +1,134 @@
+package pihole
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrResolutionMismatch = errors.New("record does not resolve to its IP")
+)
+
+// VerifyResolution looks domain up against resolver and reports whether expectedIP is among
+// the answers. resolver is "host" or "host:port" for plain DNS over UDP, or a URL with the
+// scheme udp, tcp or tls (DNS over TLS, port 853 by default), e.g. "tls://dns.lan". An empty
+// resolver uses Config.Resolver when set, and otherwise queries the Pi-hole host itself. A
+// domain that does not resolve is reported as false, not an error. DNS answers carry no
+// IPv6 zone, so a zone index on expectedIP is ignored.
+func (dns localDNS) VerifyResolution(ctx context.Context, domain string, expectedIP string, resolver string) (bool, error) {
+	expected := parseIP(expectedIP)
+	if expected == nil {
+		return false, fmt.Errorf("%w: %q", ErrInvalidIP, expectedIP)
+	}
+
+	r, resolver, err := dns.resolver(resolver)
+	if err != nil {
+		return false, err
+	}
+
+	addrs, err := r.LookupIPAddr(ctx, normalizeDomain(domain))
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to resolve %s via %s: %w", domain, resolver, err)
+	}
+
+	for _, addr := range addrs {
+		if addr.IP.Equal(expected) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// VerifyAll resolves every custom DNS record against resolver, as VerifyResolution does, and
+// returns the failures keyed by domain: an error wrapping ErrResolutionMismatch for each IP
+// the domain does not resolve to, or the lookup error. Domains whose records all resolve are
+// left out. The error is only set when the records cannot be listed or ctx ends.
+func (dns localDNS) VerifyAll(ctx context.Context, resolver string) (map[string]error, error) {
+	list, err := dns.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch custom DNS records: %w", err)
+	}
+
+	failures := make(map[string]error)
+	for _, record := range list {
+		if err := ctx.Err(); err != nil {
+			return failures, err
+		}
+
+		ok, err := dns.VerifyResolution(ctx, record.Domain, record.IP, resolver)
+		switch {
+		case err != nil:
+			failures[record.Domain] = errors.Join(failures[record.Domain], err)
+		case !ok:
+			failures[record.Domain] = errors.Join(failures[record.Domain], fmt.Errorf("%w: %s %s", ErrResolutionMismatch, record.Domain, record.IP))
+		}
+	}
+
+	return failures, nil
+}
+
+// resolver builds the net.Resolver for a VerifyResolution resolver argument, returning it
+// with a description of the server for error messages
+func (dns localDNS) resolver(resolver string) (*net.Resolver, string, error) {
+	if resolver == "" && dns.client.resolver != nil {
+		return dns.client.resolver, "the configured resolver", nil
+	}
+
+	if resolver == "" {
+		u, err := url.Parse(dns.client.baseURL)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to derive resolver from base URL: %w", err)
+		}
+		resolver = u.Hostname()
+	}
+
+	scheme, address := "udp", resolver
+	if s, rest, ok := strings.Cut(resolver, "://"); ok {
+		scheme, address = s, rest
+	}
+
+	port := "53"
+	switch scheme {
+	case "udp", "tcp":
+	case "tls":
+		port = "853"
+	default:
+		return nil, "", fmt.Errorf("unsupported resolver scheme %q, expected udp, tcp or tls", scheme)
+	}
+
+	host := address
+	if h, _, err := net.SplitHostPort(address); err == nil {
+		host = h
+	} else {
+		address = net.JoinHostPort(strings.Trim(address, "[]"), port)
+		host = strings.Trim(host, "[]")
+	}
+
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			switch scheme {
+			case "tcp":
+				network = "tcp"
+			case "tls":
+				d := tls.Dialer{Config: &tls.Config{ServerName: host}}
+				return d.DialContext(ctx, "tcp", address)
+			}
+
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		},
+	}
+
+	return r, address, nil
+}
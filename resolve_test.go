@@ -0,0 +1,133 @@
+package pihole
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// newTestResolver serves A records from records on a local UDP port and returns its address
+func newTestResolver(t *testing.T, records map[string]string) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			var msg dnsmessage.Message
+			if err := msg.Unpack(buf[:n]); err != nil || len(msg.Questions) == 0 {
+				continue
+			}
+
+			q := msg.Questions[0]
+			msg.Header.Response = true
+			msg.Header.Authoritative = true
+
+			ip, ok := records[strings.TrimSuffix(q.Name.String(), ".")]
+			switch {
+			case !ok:
+				msg.Header.RCode = dnsmessage.RCodeNameError
+			case q.Type == dnsmessage.TypeA:
+				var a dnsmessage.AResource
+				copy(a.A[:], net.ParseIP(ip).To4())
+				msg.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: 60},
+					Body:   &a,
+				}}
+			}
+
+			if out, err := msg.Pack(); err == nil {
+				_, _ = conn.WriteTo(out, addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestLocalDNSVerifyResolution(t *testing.T) {
+	isUnit(t)
+
+	resolver := newTestResolver(t, map[string]string{"test.lan": "10.0.0.1"})
+	c := newUnitTestClient(t, Config{}, newFakeLocalDNS().ServeHTTP)
+
+	t.Run("true when the record resolves to the expected IP", func(t *testing.T) {
+		ok, err := c.LocalDNS.VerifyResolution(context.Background(), "test.lan", "10.0.0.1", resolver)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("false when the record resolves to another IP", func(t *testing.T) {
+		ok, err := c.LocalDNS.VerifyResolution(context.Background(), "test.lan", "10.0.0.2", resolver)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("accept a udp URL", func(t *testing.T) {
+		ok, err := c.LocalDNS.VerifyResolution(context.Background(), "test.lan", "10.0.0.1", "udp://"+resolver)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("error on an unsupported scheme", func(t *testing.T) {
+		_, err := c.LocalDNS.VerifyResolution(context.Background(), "test.lan", "10.0.0.1", "https://"+resolver)
+		assert.Error(t, err)
+	})
+
+	t.Run("use the configured resolver by default", func(t *testing.T) {
+		custom := newUnitTestClient(t, Config{Resolver: &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "udp", resolver)
+			},
+		}}, newFakeLocalDNS().ServeHTTP)
+
+		ok, err := custom.LocalDNS.VerifyResolution(context.Background(), "test.lan", "10.0.0.1", "")
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("false when the record does not resolve", func(t *testing.T) {
+		ok, err := c.LocalDNS.VerifyResolution(context.Background(), "missing.lan", "10.0.0.1", resolver)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
+
+func TestLocalDNSVerifyAll(t *testing.T) {
+	t.Run("report only the domains that do not resolve as configured", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		resolver := newTestResolver(t, map[string]string{
+			"ok.lan":    "10.0.0.1",
+			"stale.lan": "10.0.0.9",
+		})
+		fake := newFakeLocalDNS(
+			DNSRecord{Domain: "ok.lan", IP: "10.0.0.1"},
+			DNSRecord{Domain: "stale.lan", IP: "10.0.0.2"},
+			DNSRecord{Domain: "missing.lan", IP: "10.0.0.3"},
+		)
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		failures, err := c.LocalDNS.VerifyAll(context.Background(), resolver)
+		require.NoError(t, err)
+
+		assert.Len(t, failures, 2)
+		assert.ErrorIs(t, failures["stale.lan"], ErrResolutionMismatch)
+		assert.ErrorIs(t, failures["missing.lan"], ErrResolutionMismatch)
+		assert.NotContains(t, failures, "ok.lan")
+	})
+}
@@ -0,0 +1,44 @@
+package pihole
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// retryStateKey is the context key of the retry count of a single request
+type retryStateKey struct{}
+
+// retryState counts the retries of one request so OnRetry can report the attempt number
+type retryState struct {
+	attempt int
+}
+
+// withRetryState attaches a fresh retry count to the context of a request
+func withRetryState(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryStateKey{}, &retryState{})
+}
+
+// notifyRetry reports a retry the client is about to make to onRetry, with the wait the
+// client's backoff will use. Retries past RetryMax are not made and not reported.
+func notifyRetry(ctx context.Context, rc *retryablehttp.Client, res *http.Response, err error, onRetry func(attempt int, err error, next time.Duration)) {
+	state, ok := ctx.Value(retryStateKey{}).(*retryState)
+	if !ok {
+		return
+	}
+
+	state.attempt++
+	if state.attempt > rc.RetryMax {
+		return
+	}
+
+	cause := err
+	if cause == nil && res != nil {
+		cause = fmt.Errorf("%w: %s", ErrUnexpectedStatus, res.Status)
+	}
+
+	onRetry(state.attempt, cause, rc.Backoff(rc.RetryWaitMin, rc.RetryWaitMax, state.attempt-1, res))
+}
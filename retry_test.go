@@ -0,0 +1,80 @@
+package pihole
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnRetry(t *testing.T) {
+	t.Run("report each retry before waiting", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		type retry struct {
+			attempt int
+			err     error
+			next    time.Duration
+		}
+		var retries []retry
+
+		fake := newFakeLocalDNS()
+		var requests int32
+		c := newUnitTestClient(t, Config{
+			OnRetry: func(attempt int, err error, next time.Duration) {
+				retries = append(retries, retry{attempt: attempt, err: err, next: next})
+			},
+		}, func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) <= 2 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fake.ServeHTTP(w, r)
+		})
+
+		_, err := c.LocalDNS.List(context.Background())
+		require.NoError(t, err)
+
+		require.Len(t, retries, 2)
+		for i, r := range retries {
+			assert.Equal(t, i+1, r.attempt)
+			assert.ErrorIs(t, r.err, ErrUnexpectedStatus)
+			assert.Zero(t, r.next)
+		}
+	})
+
+	t.Run("count retries per request", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		var attempts []int
+
+		fake := newFakeLocalDNS()
+		var requests int32
+		c := newUnitTestClient(t, Config{
+			OnRetry: func(attempt int, err error, next time.Duration) {
+				attempts = append(attempts, attempt)
+			},
+		}, func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1)%2 == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fake.ServeHTTP(w, r)
+		})
+
+		for i := 0; i < 2; i++ {
+			_, err := c.LocalDNS.List(context.Background())
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, []int{1, 1}, attempts)
+	})
+}
@@ -0,0 +1,335 @@
+package pihole
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Stats interface {
+	// CacheInfo returns FTL's DNS cache statistics
+	CacheInfo(ctx context.Context) (*CacheInfo, error)
+
+	// Summary returns today's query totals and the blocklist state
+	Summary(ctx context.Context) (*Summary, error)
+
+	// DomainActivity returns how often domain was queried and blocked in the last 24 hours
+	DomainActivity(ctx context.Context, domain string) (queries, blocked int, err error)
+
+	// ClientQueryCounts returns the number of queries for up to 20 of the busiest clients
+	// over the last window
+	ClientQueryCounts(ctx context.Context, window time.Duration) (map[string]int, error)
+
+	// TopDomainsOverRange returns the most queried permitted domains between from and to
+	TopDomainsOverRange(ctx context.Context, from, to time.Time, count int) ([]TopItem, error)
+
+	// TopBlockedOverRange returns the most queried blocked domains between from and to
+	TopBlockedOverRange(ctx context.Context, from, to time.Time, count int) ([]TopItem, error)
+}
+
+type stats struct {
+	client *Client
+}
+
+// CacheInfo holds FTL's DNS cache statistics. Pi-hole v5 reports no separate eviction
+// counter; LiveFreed, the entries removed to make room while still valid, is that count.
+type CacheInfo struct {
+	Size      int
+	Inserted  int
+	LiveFreed int
+}
+
+type cacheInfoResponse struct {
+	CacheInfo struct {
+		Size      float64 `json:"cache-size"`
+		Inserted  float64 `json:"cache-inserted"`
+		LiveFreed float64 `json:"cache-live-freed"`
+	} `json:"cacheinfo"`
+	FTLNotRunning bool `json:"FTLnotrunning"`
+}
+
+// CacheInfo returns FTL's DNS cache statistics
+func (s stats) CacheInfo(ctx context.Context) (*CacheInfo, error) {
+	req, err := s.client.Request(ctx, url.Values{
+		"getCacheInfo": []string{"true"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.client.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer drainAndClose(res.Body)
+
+	var cacheRes cacheInfoResponse
+	if err := s.client.decode(res.Body, &cacheRes); err != nil {
+		return nil, decodeError("cache info", err)
+	}
+
+	if cacheRes.FTLNotRunning {
+		return nil, ErrFTLNotRunning
+	}
+
+	return &CacheInfo{
+		Size:      int(cacheRes.CacheInfo.Size),
+		Inserted:  int(cacheRes.CacheInfo.Inserted),
+		LiveFreed: int(cacheRes.CacheInfo.LiveFreed),
+	}, nil
+}
+
+// Summary holds today's query totals and the state of the blocklist
+type Summary struct {
+	DomainsBeingBlocked int
+	DNSQueriesToday     int
+	AdsBlockedToday     int
+	AdsPercentageToday  float64
+	UniqueClients       int
+
+	// Status is "enabled" or "disabled"
+	Status string
+
+	// GravityLastUpdated is when the blocklist was last rebuilt, or zero when Pi-hole has
+	// never built one
+	GravityLastUpdated time.Time
+}
+
+type summaryResponse struct {
+	DomainsBeingBlocked int     `json:"domains_being_blocked"`
+	DNSQueriesToday     int     `json:"dns_queries_today"`
+	AdsBlockedToday     int     `json:"ads_blocked_today"`
+	AdsPercentageToday  float64 `json:"ads_percentage_today"`
+	UniqueClients       int     `json:"unique_clients"`
+	Status              string  `json:"status"`
+	GravityLastUpdated  struct {
+		FileExists bool  `json:"file_exists"`
+		Absolute   int64 `json:"absolute"`
+	} `json:"gravity_last_updated"`
+	FTLNotRunning bool `json:"FTLnotrunning"`
+}
+
+func (res summaryResponse) toSummary() *Summary {
+	summary := &Summary{
+		DomainsBeingBlocked: res.DomainsBeingBlocked,
+		DNSQueriesToday:     res.DNSQueriesToday,
+		AdsBlockedToday:     res.AdsBlockedToday,
+		AdsPercentageToday:  res.AdsPercentageToday,
+		UniqueClients:       res.UniqueClients,
+		Status:              res.Status,
+	}
+
+	if res.GravityLastUpdated.FileExists {
+		summary.GravityLastUpdated = epochTime(res.GravityLastUpdated.Absolute)
+	}
+
+	return summary
+}
+
+// Summary returns today's query totals and the blocklist state. The raw summary is
+// requested so counts arrive as numbers rather than formatted strings.
+func (s stats) Summary(ctx context.Context) (*Summary, error) {
+	req, err := s.client.Request(ctx, url.Values{
+		"summaryRaw": []string{"true"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.client.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer drainAndClose(res.Body)
+
+	var summaryRes summaryResponse
+	if err := s.client.decode(res.Body, &summaryRes); err != nil {
+		return nil, decodeError("summary", err)
+	}
+
+	if summaryRes.FTLNotRunning {
+		return nil, ErrFTLNotRunning
+	}
+
+	return summaryRes.toSummary(), nil
+}
+
+// DomainActivity counts the queries for domain over the last DefaultQueryWindow in the
+// long-term database and how many of them were blocked. Pi-hole v5 has no per-domain
+// aggregate outside the top 20 lists, so the domain's rows are fetched, filtered by
+// Pi-hole, and counted here. A domain queried more often than fits in MaxResponseBytes
+// within the window fails with ErrResponseTooLarge.
+func (s stats) DomainActivity(ctx context.Context, domain string) (queries, blocked int, err error) {
+	to := s.client.now()
+	results, err := s.client.Queries.Search(ctx, QueryFilter{
+		Domain: normalizeDomain(domain),
+		From:   to.Add(-DefaultQueryWindow),
+		To:     to,
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to fetch queries for %s: %w", domain, err)
+	}
+
+	for _, query := range results {
+		if query.Status.Blocked() {
+			blocked++
+		}
+	}
+
+	return len(results), blocked, nil
+}
+
+type topClientsResponse struct {
+	TopSources map[string]int `json:"top_sources"`
+}
+
+// ClientQueryCounts returns the number of queries each client sent over the last window,
+// read from the long-term database. Clients are keyed by host name when Pi-hole knows one
+// and by IP otherwise; counts of addresses sharing a name are summed. Pi-hole returns at
+// most 20 clients, so quieter clients are missing from the map.
+func (s stats) ClientQueryCounts(ctx context.Context, window time.Duration) (map[string]int, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("query count window must be positive, got %s", window)
+	}
+
+	until := s.client.now()
+	from := until.Add(-window)
+
+	req, err := s.client.request(ctx, "api_db.php", url.Values{
+		"topClients": []string{"true"},
+		"from":       []string{strconv.FormatInt(from.Unix(), 10)},
+		"until":      []string{strconv.FormatInt(until.Unix(), 10)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.client.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer drainAndClose(res.Body)
+
+	var topRes topClientsResponse
+	if err := s.client.decode(res.Body, &topRes); err != nil {
+		return nil, decodeError("top clients", err)
+	}
+
+	counts := make(map[string]int, len(topRes.TopSources))
+	for source, count := range topRes.TopSources {
+		counts[clientName(source)] += count
+	}
+
+	return counts, nil
+}
+
+// clientName picks the friendly name out of a "name|IP" client key, falling back to the IP
+func clientName(source string) string {
+	name, ip, ok := strings.Cut(source, "|")
+	if !ok {
+		return source
+	}
+	if name == "" {
+		return ip
+	}
+
+	return name
+}
+
+// TopItem is a domain and the number of times it was queried
+type TopItem struct {
+	Domain string
+	Count  int
+}
+
+// TopDomainsOverRange returns up to count of the most queried permitted domains between from
+// and to, read from the long-term database and sorted by count, then domain. Pi-hole returns
+// at most 20 domains, so a larger count is capped.
+func (s stats) TopDomainsOverRange(ctx context.Context, from, to time.Time, count int) ([]TopItem, error) {
+	return s.topOverRange(ctx, "topDomains", "top_domains", from, to, count)
+}
+
+// TopBlockedOverRange returns up to count of the most queried blocked domains between from
+// and to, read from the long-term database and sorted by count, then domain. Pi-hole returns
+// at most 20 domains, so a larger count is capped.
+func (s stats) TopBlockedOverRange(ctx context.Context, from, to time.Time, count int) ([]TopItem, error) {
+	return s.topOverRange(ctx, "topAds", "top_ads", from, to, count)
+}
+
+// topOverRange requests the api_db.php top list action and decodes the list under key
+func (s stats) topOverRange(ctx context.Context, action string, key string, from, to time.Time, count int) ([]TopItem, error) {
+	if count <= 0 {
+		return nil, fmt.Errorf("top list count must be positive, got %d", count)
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("top list range ends at %s before it starts at %s", to, from)
+	}
+
+	req, err := s.client.request(ctx, "api_db.php", url.Values{
+		action:  []string{"true"},
+		"from":  []string{strconv.FormatInt(from.Unix(), 10)},
+		"until": []string{strconv.FormatInt(to.Unix(), 10)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.client.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer drainAndClose(res.Body)
+
+	var topRes map[string]json.RawMessage
+	if err := s.client.decode(res.Body, &topRes); err != nil {
+		return nil, decodeError(action, err)
+	}
+
+	items, err := parseTopItems(topRes[key])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s body: %w", action, err)
+	}
+
+	if count < len(items) {
+		items = items[:count]
+	}
+
+	return items, nil
+}
+
+// parseTopItems decodes a domain to count object, which PHP encodes as [] when it is empty,
+// into items sorted by descending count, then domain
+func parseTopItems(raw json.RawMessage) ([]TopItem, error) {
+	items := []TopItem{}
+	if len(raw) == 0 || bytes.Equal(raw, []byte("[]")) {
+		return items, nil
+	}
+
+	var counts map[string]int
+	if err := json.Unmarshal(raw, &counts); err != nil {
+		return nil, err
+	}
+
+	for domain, count := range counts {
+		items = append(items, TopItem{Domain: domain, Count: count})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].Domain < items[j].Domain
+	})
+
+	return items, nil
+}
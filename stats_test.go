@@ -0,0 +1,224 @@
+package pihole
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsCacheInfo(t *testing.T) {
+	t.Run("parse cache info", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			assert.True(t, r.URL.Query().Has("getCacheInfo"))
+			fmt.Fprint(w, `{"cacheinfo":{"cache-size":10000,"cache-live-freed":3,"cache-inserted":420}}`)
+		})
+
+		info, err := c.Stats.CacheInfo(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, &CacheInfo{Size: 10000, Inserted: 420, LiveFreed: 3}, info)
+	})
+
+	t.Run("error when FTL is not running", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"FTLnotrunning":true}`)
+		})
+
+		_, err := c.Stats.CacheInfo(context.Background())
+		assert.ErrorIs(t, err, ErrFTLNotRunning)
+	})
+
+	t.Run("fetch cache info", func(t *testing.T) {
+		isAcceptance(t)
+
+		c := newTestClient(t)
+
+		info, err := c.Stats.CacheInfo(context.Background())
+		require.NoError(t, err)
+		assert.Positive(t, info.Size)
+	})
+}
+
+func TestStatsClientQueryCounts(t *testing.T) {
+	t.Run("prefer client names and sum shared names", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+			assert.Equal(t, "/admin/api_db.php", r.URL.Path)
+			assert.True(t, q.Has("topClients"))
+
+			from, err := strconv.ParseInt(q.Get("from"), 10, 64)
+			require.NoError(t, err)
+			until, err := strconv.ParseInt(q.Get("until"), 10, 64)
+			require.NoError(t, err)
+			assert.Equal(t, int64(3600), until-from)
+
+			fmt.Fprint(w, `{"top_sources":{"laptop|10.0.0.5":40,"laptop|fd00::5":2,"10.0.0.9":7,"|10.0.0.10":1}}`)
+		})
+
+		counts, err := c.Stats.ClientQueryCounts(context.Background(), time.Hour)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]int{"laptop": 42, "10.0.0.9": 7, "10.0.0.10": 1}, counts)
+	})
+
+	t.Run("reject a non-positive window", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			t.Error("unexpected request")
+		})
+
+		_, err := c.Stats.ClientQueryCounts(context.Background(), 0)
+		assert.Error(t, err)
+	})
+}
+
+func TestStatsSummary(t *testing.T) {
+	t.Run("parse gravity timestamp and counts", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			assert.True(t, r.URL.Query().Has("summaryRaw"))
+			fmt.Fprint(w, `{"domains_being_blocked":123456,"dns_queries_today":900,"ads_blocked_today":90,`+
+				`"ads_percentage_today":10.0,"unique_clients":7,"status":"enabled",`+
+				`"gravity_last_updated":{"file_exists":true,"absolute":1650000000,"relative":{"days":2,"hours":1,"minutes":5}}}`)
+		})
+
+		summary, err := c.Stats.Summary(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, &Summary{
+			DomainsBeingBlocked: 123456,
+			DNSQueriesToday:     900,
+			AdsBlockedToday:     90,
+			AdsPercentageToday:  10.0,
+			UniqueClients:       7,
+			Status:              "enabled",
+			GravityLastUpdated:  time.Unix(1650000000, 0).UTC(),
+		}, summary)
+	})
+
+	t.Run("leave the gravity timestamp zero without a blocklist", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"domains_being_blocked":0,"status":"enabled","gravity_last_updated":{"file_exists":false}}`)
+		})
+
+		summary, err := c.Stats.Summary(context.Background())
+		require.NoError(t, err)
+		assert.True(t, summary.GravityLastUpdated.IsZero())
+	})
+
+	t.Run("fetch summary", func(t *testing.T) {
+		isAcceptance(t)
+
+		c := newTestClient(t)
+
+		summary, err := c.Stats.Summary(context.Background())
+		require.NoError(t, err)
+		assert.NotEmpty(t, summary.Status)
+	})
+}
+
+func TestStatsDomainActivity(t *testing.T) {
+	t.Run("count queries and blocked queries", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+			assert.Equal(t, "ads.example.com", q.Get("domain"))
+
+			from, err := strconv.ParseInt(q.Get("from"), 10, 64)
+			require.NoError(t, err)
+			until, err := strconv.ParseInt(q.Get("until"), 10, 64)
+			require.NoError(t, err)
+			assert.Equal(t, int64(DefaultQueryWindow/time.Second), until-from)
+
+			fmt.Fprint(w, `{"data":[`+
+				`[1650000100,"A","ads.example.com","10.0.0.5",1,0],`+
+				`[1650000200,"A","ads.example.com","10.0.0.5",2,0],`+
+				`[1650000300,"A","ads.example.com","10.0.0.6",9,0]]}`)
+		})
+
+		queries, blocked, err := c.Stats.DomainActivity(context.Background(), "Ads.Example.com")
+		require.NoError(t, err)
+
+		assert.Equal(t, 3, queries)
+		assert.Equal(t, 2, blocked)
+	})
+}
+
+func TestStatsTopOverRange(t *testing.T) {
+	from := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("request the range and sort the top domains", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+			assert.Equal(t, "/admin/api_db.php", r.URL.Path)
+			assert.True(t, q.Has("topDomains"))
+			assert.Equal(t, strconv.FormatInt(from.Unix(), 10), q.Get("from"))
+			assert.Equal(t, strconv.FormatInt(to.Unix(), 10), q.Get("until"))
+
+			fmt.Fprint(w, `{"top_domains":{"b.example.com":5,"c.example.com":9,"a.example.com":5}}`)
+		})
+
+		items, err := c.Stats.TopDomainsOverRange(context.Background(), from, to, 2)
+		require.NoError(t, err)
+
+		assert.Equal(t, []TopItem{
+			{Domain: "c.example.com", Count: 9},
+			{Domain: "a.example.com", Count: 5},
+		}, items)
+	})
+
+	t.Run("return no blocked domains for an empty range", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			assert.True(t, r.URL.Query().Has("topAds"))
+			fmt.Fprint(w, `{"top_ads":[]}`)
+		})
+
+		items, err := c.Stats.TopBlockedOverRange(context.Background(), from, to, 10)
+		require.NoError(t, err)
+
+		assert.Empty(t, items)
+	})
+
+	t.Run("reject a reversed range", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			t.Error("unexpected request")
+		})
+
+		_, err := c.Stats.TopDomainsOverRange(context.Background(), to, from, 10)
+		assert.Error(t, err)
+	})
+}
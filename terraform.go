@@ -0,0 +1,61 @@
+package pihole
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ExportTerraformImports writes a Terraform import block for every custom DNS record, in
+// server order, addressing each as resourceType.<name> with the ID "domain/IP". Names are
+// derived from the domain and IP and made unique.
+func (dns localDNS) ExportTerraformImports(ctx context.Context, resourceType string, w io.Writer) error {
+	if !isTerraformIdentifier(resourceType) {
+		return fmt.Errorf("invalid Terraform resource type %q", resourceType)
+	}
+
+	list, err := dns.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch custom DNS records: %w", err)
+	}
+
+	used := make(map[string]bool, len(list))
+	for _, record := range list {
+		base := terraformName(record.Domain + "_" + record.IP)
+		name := base
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+		used[name] = true
+
+		if _, err := fmt.Fprintf(w, "import {\n  to = %s.%s\n  id = %s\n}\n\n", resourceType, name, strconv.Quote(record.Domain+"/"+record.IP)); err != nil {
+			return fmt.Errorf("failed to write Terraform imports: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// terraformName turns s into a Terraform resource name, replacing every character that is
+// not a letter, digit or underscore
+func terraformName(s string) string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "r_" + name
+	}
+
+	return name
+}
+
+// isTerraformIdentifier reports whether s can be used as is in a resource address
+func isTerraformIdentifier(s string) bool {
+	return s != "" && terraformName(s) == s
+}
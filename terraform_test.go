@@ -0,0 +1,60 @@
+package pihole
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalDNSExportTerraformImports(t *testing.T) {
+	t.Run("write an import block per record", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(
+			DNSRecord{Domain: "nas.lan", IP: "10.0.0.1"},
+			DNSRecord{Domain: "nas.lan", IP: "fd00::1"},
+			DNSRecord{Domain: "nas-lan", IP: "10.0.0.1"},
+			DNSRecord{Domain: "1.lan", IP: "10.0.0.2"},
+		)
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		var b strings.Builder
+		require.NoError(t, c.LocalDNS.ExportTerraformImports(context.Background(), "pihole_dns_record", &b))
+
+		assert.Equal(t, `import {
+  to = pihole_dns_record.nas_lan_10_0_0_1
+  id = "nas.lan/10.0.0.1"
+}
+
+import {
+  to = pihole_dns_record.nas_lan_fd00__1
+  id = "nas.lan/fd00::1"
+}
+
+import {
+  to = pihole_dns_record.nas_lan_10_0_0_1_2
+  id = "nas-lan/10.0.0.1"
+}
+
+import {
+  to = pihole_dns_record.r_1_lan_10_0_0_2
+  id = "1.lan/10.0.0.2"
+}
+
+`, b.String())
+	})
+
+	t.Run("reject an invalid resource type", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, newFakeLocalDNS().ServeHTTP)
+
+		err := c.LocalDNS.ExportTerraformImports(context.Background(), "pihole.record", &strings.Builder{})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,80 @@
+package pihole
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenFile holds an API token read from a file, re-reading it whenever the file's
+// modification time changes so rotated secrets are picked up without a new client
+type tokenFile struct {
+	path string
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+}
+
+// newTokenFile reads the token file once so a missing or empty file fails construction
+func newTokenFile(path string) (*tokenFile, error) {
+	tf := &tokenFile{path: path}
+	if err := tf.reload(); err != nil {
+		return nil, err
+	}
+
+	return tf, nil
+}
+
+// get returns the current token. When the file changed but cannot be read, the last token
+// read is kept.
+func (tf *tokenFile) get() string {
+	tf.mu.Lock()
+	defer tf.mu.Unlock()
+
+	if info, err := os.Stat(tf.path); err == nil && !info.ModTime().Equal(tf.modTime) {
+		_ = tf.reloadLocked()
+	}
+
+	return tf.token
+}
+
+func (tf *tokenFile) reload() error {
+	tf.mu.Lock()
+	defer tf.mu.Unlock()
+
+	return tf.reloadLocked()
+}
+
+func (tf *tokenFile) reloadLocked() error {
+	info, err := os.Stat(tf.path)
+	if err != nil {
+		return fmt.Errorf("failed to read API token file: %w", err)
+	}
+
+	b, err := os.ReadFile(tf.path)
+	if err != nil {
+		return fmt.Errorf("failed to read API token file: %w", err)
+	}
+
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return fmt.Errorf("API token file %s is empty", tf.path)
+	}
+
+	tf.token = token
+	tf.modTime = info.ModTime()
+
+	return nil
+}
+
+// token returns the API token to authenticate requests with
+func (c Client) token() string {
+	if c.tokenFile != nil {
+		return c.tokenFile.get()
+	}
+
+	return c.apiToken
+}
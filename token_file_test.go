@@ -0,0 +1,86 @@
+package pihole
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPITokenFile(t *testing.T) {
+	t.Run("authenticate with the trimmed file contents", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(path, []byte("  secret\n"), 0o600))
+
+		var mu sync.Mutex
+		var tokens []string
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{APITokenFile: path}, func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			tokens = append(tokens, r.URL.Query().Get("auth"))
+			mu.Unlock()
+			fake.ServeHTTP(w, r)
+		})
+
+		_, err := c.LocalDNS.List(context.Background())
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(path, []byte("rotated\n"), 0o600))
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(path, later, later))
+
+		_, err = c.LocalDNS.List(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"secret", "rotated"}, tokens)
+	})
+
+	t.Run("keep the last token when the file disappears", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(path, []byte("secret"), 0o600))
+
+		tf, err := newTokenFile(path)
+		require.NoError(t, err)
+
+		require.NoError(t, os.Remove(path))
+		assert.Equal(t, "secret", tf.get())
+	})
+
+	t.Run("error on a missing or empty file", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		dir := t.TempDir()
+		empty := filepath.Join(dir, "empty")
+		require.NoError(t, os.WriteFile(empty, []byte("\n"), 0o600))
+
+		_, err := New(Config{BaseURL: "http://pi.hole", APITokenFile: filepath.Join(dir, "missing")})
+		assert.ErrorIs(t, err, ErrClientValidation)
+
+		_, err = New(Config{BaseURL: "http://pi.hole", APITokenFile: empty})
+		assert.ErrorIs(t, err, ErrClientValidation)
+	})
+
+	t.Run("error when combined with APIToken", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(path, []byte("secret"), 0o600))
+
+		_, err := New(Config{BaseURL: "http://pi.hole", APIToken: "token", APITokenFile: path})
+		assert.ErrorIs(t, err, ErrClientValidation)
+	})
+}
@@ -0,0 +1,162 @@
+package pihole
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrTxDone = errors.New("transaction has already been committed or rolled back")
+)
+
+// Tx stages DNS and CNAME changes and applies them together on Commit.
+//
+// Pi-hole has no transactions, so a Tx is best effort: changes are sent one at a time and
+// become visible to other clients as soon as each one succeeds. When Commit fails part way,
+// Rollback undoes the applied changes with compensating requests, which can fail too, and
+// cannot restore changes other clients made in between. A Tx is not safe for concurrent use.
+type Tx struct {
+	client    *Client
+	ops       []txOp
+	applied   []txOp
+	done      bool
+	committed bool
+}
+
+// txOp is a single staged change, named by the audit action it performs
+type txOp struct {
+	action AuditAction
+	domain string
+	value  string
+}
+
+// Begin starts a new transaction
+func (c *Client) Begin() *Tx {
+	return &Tx{client: c}
+}
+
+// CreateDNS stages the creation of a custom DNS record
+func (tx *Tx) CreateDNS(domain string, IP string) *Tx {
+	tx.ops = append(tx.ops, txOp{action: AuditCreateDNS, domain: normalizeDomain(domain), value: IP})
+	return tx
+}
+
+// DeleteDNS stages the deletion of a custom DNS record, sending its domain exactly as stored
+func (tx *Tx) DeleteDNS(record DNSRecord) *Tx {
+	tx.ops = append(tx.ops, txOp{action: AuditDeleteDNS, domain: record.Domain, value: record.IP})
+	return tx
+}
+
+// CreateCNAME stages the creation of a CNAME record
+func (tx *Tx) CreateCNAME(domain string, target string) *Tx {
+	tx.ops = append(tx.ops, txOp{action: AuditCreateCNAME, domain: domain, value: target})
+	return tx
+}
+
+// DeleteCNAME stages the deletion of a CNAME record
+func (tx *Tx) DeleteCNAME(record CNAMERecord) *Tx {
+	tx.ops = append(tx.ops, txOp{action: AuditDeleteCNAME, domain: record.Domain, value: record.Target})
+	return tx
+}
+
+// Commit validates the staged DNS records, then applies the changes in the order they were
+// staged. It stops at the first failure or when ctx is cancelled, leaving the changes
+// applied so far in place; call Rollback to undo them.
+func (tx *Tx) Commit(ctx context.Context) error {
+	if tx.done {
+		return ErrTxDone
+	}
+
+	ctx, cancel := tx.client.operationContext(ctx)
+	defer cancel()
+
+	var records DNSRecordList
+	for _, op := range tx.ops {
+		if op.action == AuditCreateDNS {
+			records = append(records, DNSRecord{Domain: op.domain, IP: op.value})
+		}
+	}
+	if _, err := normalizeRecords(records); err != nil {
+		return err
+	}
+
+	tx.done = true
+
+	for _, op := range tx.ops {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("applied %d of %d changes: %w", len(tx.applied), len(tx.ops), err)
+		}
+
+		opCtx, cancelOp := recordContext(ctx)
+		err := tx.apply(opCtx, op, false)
+		cancelOp()
+		if err != nil {
+			return fmt.Errorf("applied %d of %d changes: %w", len(tx.applied), len(tx.ops), err)
+		}
+		tx.applied = append(tx.applied, op)
+	}
+
+	tx.applied = nil
+	tx.committed = true
+
+	return nil
+}
+
+// Rollback undoes the changes applied by a failed Commit in reverse order. Before Commit it
+// only discards the staged changes. Each compensating request is attempted even when an
+// earlier one fails; the failures are joined in the returned error. After a successful
+// Commit it changes nothing and returns ErrTxDone, so it can be deferred right after Begin.
+// The compensating requests are detached from ctx cancellation, so a rollback deferred with
+// the ctx that cancelled Commit still undoes its changes.
+func (tx *Tx) Rollback(ctx context.Context) error {
+	if tx.committed {
+		return ErrTxDone
+	}
+
+	tx.done = true
+
+	var errs []error
+	for i := len(tx.applied) - 1; i >= 0; i-- {
+		opCtx, cancelOp := recordContext(ctx)
+		err := tx.apply(opCtx, tx.applied[i], true)
+		cancelOp()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	tx.applied = nil
+
+	return errors.Join(errs...)
+}
+
+// apply performs op, or its compensating change when undo is set
+func (tx *Tx) apply(ctx context.Context, op txOp, undo bool) error {
+	dns := localDNS{client: tx.client}
+	cname := localCNAME{client: tx.client}
+
+	switch op.action {
+	case AuditCreateDNS:
+		if undo {
+			return dns.deleteRecord(ctx, DNSRecord{Domain: op.domain, IP: op.value})
+		}
+		return dns.add(ctx, op.domain, op.value)
+	case AuditDeleteDNS:
+		if undo {
+			return dns.add(ctx, op.domain, op.value)
+		}
+		return dns.deleteRecord(ctx, DNSRecord{Domain: op.domain, IP: op.value})
+	case AuditCreateCNAME:
+		if undo {
+			return cname.deleteRecord(ctx, CNAMERecord{Domain: op.domain, Target: op.value})
+		}
+		return cname.add(ctx, op.domain, op.value)
+	case AuditDeleteCNAME:
+		if undo {
+			return cname.add(ctx, op.domain, op.value)
+		}
+		return cname.deleteRecord(ctx, CNAMERecord{Domain: op.domain, Target: op.value})
+	}
+
+	return fmt.Errorf("unknown transaction action %q", op.action)
+}
@@ -0,0 +1,136 @@
+package pihole
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTx(t *testing.T) {
+	t.Run("apply staged changes on commit", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(DNSRecord{Domain: "old.lan", IP: "10.0.0.1"})
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		tx := c.Begin().
+			CreateDNS("New.lan", "10.0.0.2").
+			DeleteDNS(DNSRecord{Domain: "old.lan", IP: "10.0.0.1"})
+
+		assert.Equal(t, DNSRecordList{{Domain: "old.lan", IP: "10.0.0.1"}}, fake.list())
+
+		require.NoError(t, tx.Commit(context.Background()))
+		assert.Equal(t, DNSRecordList{{Domain: "new.lan", IP: "10.0.0.2"}}, fake.list())
+
+		assert.ErrorIs(t, tx.Commit(context.Background()), ErrTxDone)
+	})
+
+	t.Run("roll back applied changes after a failure", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS(DNSRecord{Domain: "old.lan", IP: "10.0.0.1"})
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+		ctx := context.Background()
+
+		tx := c.Begin().
+			CreateDNS("new.lan", "10.0.0.2").
+			DeleteDNS(DNSRecord{Domain: "old.lan", IP: "10.0.0.1"}).
+			DeleteDNS(DNSRecord{Domain: "missing.lan", IP: "10.0.0.3"})
+
+		err := tx.Commit(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "applied 2 of 3 changes")
+
+		require.NoError(t, tx.Rollback(ctx))
+		assert.Equal(t, DNSRecordList{{Domain: "old.lan", IP: "10.0.0.1"}}, fake.list())
+	})
+
+	t.Run("roll back on the context that cancelled commit", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			fake.ServeHTTP(w, r)
+			if r.URL.Query().Get("action") == "add" {
+				cancel()
+			}
+		})
+
+		tx := c.Begin().
+			CreateDNS("a.lan", "10.0.0.1").
+			CreateDNS("b.lan", "10.0.0.2")
+
+		err := tx.Commit(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, DNSRecordList{{Domain: "a.lan", IP: "10.0.0.1"}}, fake.list())
+
+		require.NoError(t, tx.Rollback(ctx))
+		assert.Empty(t, fake.list())
+	})
+
+	t.Run("keep committed changes on a deferred rollback", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+		ctx := context.Background()
+
+		tx := c.Begin().CreateDNS("a.lan", "10.0.0.1")
+		require.NoError(t, tx.Commit(ctx))
+
+		assert.ErrorIs(t, tx.Rollback(ctx), ErrTxDone)
+		assert.Equal(t, DNSRecordList{{Domain: "a.lan", IP: "10.0.0.1"}}, fake.list())
+	})
+
+	t.Run("delete a record by its stored domain", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		stored := DNSRecord{Domain: "Host.LAN.", IP: "10.0.0.1"}
+		fake := newFakeLocalDNS(stored)
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		require.NoError(t, c.Begin().DeleteDNS(stored).Commit(context.Background()))
+		assert.Empty(t, fake.list())
+	})
+
+	t.Run("validate before applying anything", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+
+		err := c.Begin().
+			CreateDNS("good.lan", "10.0.0.1").
+			CreateDNS("bad.lan", "not-an-ip").
+			Commit(context.Background())
+		assert.ErrorIs(t, err, ErrInvalidIP)
+		assert.Empty(t, fake.list())
+	})
+
+	t.Run("discard staged changes on rollback before commit", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		fake := newFakeLocalDNS()
+		c := newUnitTestClient(t, Config{}, fake.ServeHTTP)
+		ctx := context.Background()
+
+		tx := c.Begin().CreateDNS("new.lan", "10.0.0.1")
+		require.NoError(t, tx.Rollback(ctx))
+
+		assert.ErrorIs(t, tx.Commit(ctx), ErrTxDone)
+		assert.Empty(t, fake.list())
+	})
+}
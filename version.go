@@ -2,7 +2,7 @@ package pihole
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 )
@@ -16,6 +16,8 @@ type version struct {
 	client *Client
 }
 
+// ComponentVersions holds the installed and latest version of each Pi-hole component. The
+// Update fields report whether the server found a newer release of that component.
 type ComponentVersions struct {
 	CoreUpdate  bool   `json:"core_update,omitempty"`
 	WebUpdate   bool   `json:"web_update,omitempty"`
@@ -31,6 +33,11 @@ type ComponentVersions struct {
 	FTLBranch   string `json:"FTL_branch,omitempty"`
 }
 
+// UpdateAvailable reports whether any component has a newer release
+func (v ComponentVersions) UpdateAvailable() bool {
+	return v.CoreUpdate || v.WebUpdate || v.FTLUpdate
+}
+
 func (v version) Get(ctx context.Context) (*ComponentVersions, error) {
 	req, err := v.client.Request(ctx, url.Values{
 		"versions": []string{"true"},
@@ -44,11 +51,14 @@ func (v version) Get(ctx context.Context) (*ComponentVersions, error) {
 		return nil, fmt.Errorf("failed to fetch versions: %w", err)
 	}
 
-	defer res.Body.Close()
+	defer drainAndClose(res.Body)
 
 	var vRes *ComponentVersions
-	if err := json.NewDecoder(res.Body).Decode(&vRes); err != nil {
-		return nil, fmt.Errorf("failed to parse versions response body: %w", err)
+	if err := v.client.decode(res.Body, &vRes); err != nil {
+		return nil, decodeError("versions", err)
+	}
+	if vRes == nil {
+		return nil, errors.New("failed to parse versions body: missing versions")
 	}
 
 	return vRes, nil
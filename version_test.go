@@ -2,6 +2,8 @@ package pihole
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -22,3 +24,31 @@ func TestVersion(t *testing.T) {
 		assert.NotEmpty(t, versions.WebCurrent)
 	})
 }
+
+func TestVersionUpdates(t *testing.T) {
+	t.Run("parse update flags and latest versions", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		c := newUnitTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"core_update":false,"web_update":false,"FTL_update":true,`+
+				`"core_current":"v5.18","web_current":"v5.21","FTL_current":"v5.24",`+
+				`"core_latest":"v5.18","web_latest":"v5.21","FTL_latest":"v5.25"}`)
+		})
+
+		versions, err := c.Version.Get(context.Background())
+		require.NoError(t, err)
+
+		assert.False(t, versions.CoreUpdate)
+		assert.True(t, versions.FTLUpdate)
+		assert.Equal(t, "v5.25", versions.FTLLatest)
+		assert.True(t, versions.UpdateAvailable())
+	})
+
+	t.Run("report no update when all components are current", func(t *testing.T) {
+		isUnit(t)
+		t.Parallel()
+
+		assert.False(t, ComponentVersions{CoreCurrent: "v5.18", CoreLatest: "v5.18"}.UpdateAvailable())
+	})
+}